@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/dunv/utask"
+	"github.com/dunv/utask/graph"
+	"github.com/dunv/utask/output"
+	"github.com/dunv/utask/taskfile"
+)
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("utask "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+// parse flags, returns false if the command should exit with exitUsage
+func parseFlags(fs *flag.FlagSet, args []string) bool {
+	return fs.Parse(args) == nil
+}
+
+func loadFile(path string, stderr io.Writer) (*taskfile.File, bool) {
+	f, err := taskfile.Load(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "utask: %s\n", err)
+		return nil, false
+	}
+	return f, true
+}
+
+// utask run [-parallel n] [-checkpoint dir [-resume id]] <file> [targets...]
+func runCommand(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("run", stderr)
+	parallel := fs.Int("parallel", 0, "maximum number of tasks running at the same time (0: unlimited)")
+	checkpoint := fs.String("checkpoint", "", "persist task results to the directory, so the run can be resumed")
+	resume := fs.String("resume", "", "resume the run with the given id (or 'last'), requires -checkpoint")
+	if !parseFlags(fs, args) {
+		return exitUsage
+	}
+	if fs.NArg() < 1 || (*resume != "" && *checkpoint == "") {
+		fmt.Fprintln(stderr, "usage: utask run [-parallel n] [-checkpoint dir [-resume id]] <file> [targets...]")
+		return exitUsage
+	}
+
+	f, ok := loadFile(fs.Arg(0), stderr)
+	if !ok {
+		return exitUsage
+	}
+	if targets := fs.Args()[1:]; len(targets) > 0 {
+		var err error
+		if f, err = f.Select(targets...); err != nil {
+			fmt.Fprintf(stderr, "utask: %s\n", err)
+			return exitUsage
+		}
+	}
+
+	runID := *resume
+	if runID == "last" {
+		ids, err := graph.ListCheckpoints(*checkpoint)
+		if err != nil || len(ids) == 0 {
+			fmt.Fprintf(stderr, "utask: no run to resume in %s\n", *checkpoint)
+			return exitUsage
+		}
+		runID = ids[len(ids)-1]
+	}
+
+	opts := []graph.Option{
+		graph.WithParallelism(*parallel),
+		graph.WithNodeHook(func(r graph.NodeResult) {
+			runID = r.RunID
+			switch {
+			case r.Resumed:
+				fmt.Fprintf(stderr, "utask: %s succeeded in a previous attempt of the run\n", r.Name)
+			case r.Status == graph.StatusSucceeded:
+				fmt.Fprintf(stderr, "utask: %s succeeded after %s\n", r.Name, output.FormatDuration(r.Ended.Sub(r.Started)))
+			case r.Status == graph.StatusFailed:
+				fmt.Fprintf(stderr, "utask: %s failed after %s (%d attempts): %s\n", r.Name, output.FormatDuration(r.Ended.Sub(r.Started)), r.Attempts, r.Err)
+			case r.Status == graph.StatusSkipped:
+				fmt.Fprintf(stderr, "utask: %s skipped: %s\n", r.Name, r.Err)
+			}
+		}),
+	}
+	if *checkpoint != "" {
+		opts = append(opts, graph.WithCheckpoint(*checkpoint))
+	}
+	g, err := f.GraphWithTaskOptions(taskOptions(ctx), opts...)
+	if err != nil {
+		fmt.Fprintf(stderr, "utask: %s\n", err)
+		return exitUsage
+	}
+
+	if *resume != "" {
+		err = g.Resume(ctx, runID)
+	} else {
+		err = g.Run(ctx)
+	}
+	code := exitCode(ctx, err)
+	if code == exitTimeout {
+		// a timeout of a single task is a failure of the run
+		code = exitFailure
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Fprintf(stderr, "utask: %s\n", strings.SplitN(err.Error(), "\n", 2)[0])
+	}
+	if err != nil && *checkpoint != "" && runID != "" {
+		fmt.Fprintf(stderr, "utask: resume with -checkpoint %s -resume %s\n", *checkpoint, runID)
+	}
+	return code
+}
+
+// utask exec [-timeout d] [-term-signal sig] [-wait-delay d] -- <command> [args...]
+func execCommand(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("exec", stderr)
+	timeout := fs.Duration("timeout", 0, "stop the command after the given duration (0: no timeout)")
+	termSignal := fs.String("term-signal", "SIGTERM", "signal sent to the process group of the command to stop it")
+	waitDelay := fs.Duration("wait-delay", time.Second, "time between the term signal and SIGKILL")
+	if !parseFlags(fs, args) {
+		return exitUsage
+	}
+	if fs.NArg() < 1 {
+		fmt.Fprintln(stderr, "usage: utask exec [-timeout d] [-term-signal sig] [-wait-delay d] -- <command> [args...]")
+		return exitUsage
+	}
+
+	// reuse the validation and signal names of task files
+	delay := taskfile.Duration(*waitDelay)
+	def := taskfile.Task{
+		Name:       fs.Arg(0),
+		Command:    fs.Arg(0),
+		Args:       fs.Args()[1:],
+		TermSignal: *termSignal,
+		WaitDelay:  &delay,
+	}
+	if err := def.Validate(); err != nil {
+		fmt.Fprintf(stderr, "utask: %s\n", err)
+		return exitUsage
+	}
+
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	task, err := def.ShellTask(ctx, append(taskOptions(ctx),
+		utask.WithShellStdout(stdout),
+		utask.WithShellStderr(stderr),
+	)...)
+	if err != nil {
+		fmt.Fprintf(stderr, "utask: %s\n", err)
+		return exitUsage
+	}
+
+	err = task.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		fmt.Fprintf(stderr, "utask: %s timed out after %s\n", def.Command, *timeout)
+		return exitTimeout
+	}
+	var execErr *exec.Error
+	if errors.As(err, &execErr) {
+		fmt.Fprintf(stderr, "utask: %s\n", err)
+		// same as the shell for commands which are not found
+		return 127
+	}
+	return commandExitCode(ctx, err)
+}
+
+// utask list <file>
+func listCommand(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	f, code := graphOf(args, "list", stderr)
+	if f == nil {
+		return code
+	}
+
+	nodes, _ := f.graph.Nodes()
+	width := 0
+	for _, n := range nodes {
+		width = max(width, len(n.Name))
+	}
+	for _, n := range nodes {
+		t := f.file.Task(n.Name)
+		line := fmt.Sprintf("%-*s  %s", width, n.Name, strings.Join(append([]string{t.Command}, t.Args...), " "))
+		if len(n.Needs) > 0 {
+			line += fmt.Sprintf("  (needs %s)", strings.Join(n.Needs, ", "))
+		}
+		fmt.Fprintln(stdout, line)
+	}
+	return exitOK
+}
+
+// utask graph <file>
+func graphCommand(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	f, code := graphOf(args, "graph", stderr)
+	if f == nil {
+		return code
+	}
+
+	nodes, _ := f.graph.Nodes()
+	fmt.Fprintln(stdout, "digraph utask {")
+	for _, n := range nodes {
+		fmt.Fprintf(stdout, "  %q;\n", n.Name)
+	}
+	for _, n := range nodes {
+		for _, need := range n.Needs {
+			fmt.Fprintf(stdout, "  %q -> %q;\n", need, n.Name)
+		}
+	}
+	fmt.Fprintln(stdout, "}")
+	return exitOK
+}
+
+type loadedGraph struct {
+	file  *taskfile.File
+	graph graph.Graph
+}
+
+func graphOf(args []string, name string, stderr io.Writer) (*loadedGraph, int) {
+	fs := newFlagSet(name, stderr)
+	if !parseFlags(fs, args) {
+		return nil, exitUsage
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(stderr, "usage: utask %s <file>\n", name)
+		return nil, exitUsage
+	}
+	f, ok := loadFile(fs.Arg(0), stderr)
+	if !ok {
+		return nil, exitUsage
+	}
+	g, err := f.Graph()
+	if err != nil {
+		fmt.Fprintf(stderr, "utask: %s\n", err)
+		return nil, exitUsage
+	}
+	return &loadedGraph{file: f, graph: g}, exitOK
+}
@@ -0,0 +1,198 @@
+// Command utask runs task files and ad-hoc commands
+//
+//	utask run [flags] <file> [targets...]       run all tasks (or targets and the tasks they need)
+//	utask exec [flags] -- <command> [args...]   run a single command
+//	utask list <file>                           list the tasks of a file
+//	utask graph <file>                          print the dependency graph in DOT format
+//
+// With -checkpoint <dir>, run persists the result of every task, a failed run can be
+// continued with -resume <id> (or -resume last), only running tasks which did not succeed.
+//
+// SIGINT and SIGTERM are relayed to the running tasks. Tasks still running 10s later are
+// stopped with their term signal (after their wait delay with SIGKILL). A second SIGINT
+// or SIGTERM terminates utask immediately.
+//
+// Exit codes
+//   - 0: success
+//   - 1: a task failed
+//   - 2: invalid usage or task file
+//   - 124: the command timed out (exec)
+//   - 128+n: interrupted by signal n
+//   - exec exits with the exit code of the command
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/dunv/utask"
+)
+
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+	exitTimeout = 124
+)
+
+// time running tasks get to handle a relayed signal before they are stopped via their context
+const interruptGrace = 10 * time.Second
+
+const usage = `usage:
+  utask run [-parallel n] [-checkpoint dir [-resume id]] <file> [targets...]
+  utask exec [-timeout d] [-term-signal sig] [-wait-delay d] -- <command> [args...]
+  utask list <file>
+  utask graph <file>
+`
+
+func main() {
+	ctx, stop := notifyContext(context.Background())
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// interrupted is the cause of a context canceled by a signal
+type interrupted struct {
+	signal syscall.Signal
+}
+
+func (i interrupted) Error() string {
+	return fmt.Sprintf("interrupted by %s", i.signal)
+}
+
+// signal handling of a context returned by notifyContext
+type interruption struct {
+	// relays SIGINT and SIGTERM to the process groups of running tasks
+	forward utask.ShellTaskOption
+	// first signal received, 0 if none
+	signal atomic.Int32
+}
+
+type interruptionKey struct{}
+
+// options of tasks started with ctx, so they receive the signals relayed by notifyContext
+func taskOptions(ctx context.Context) []utask.ShellTaskOption {
+	if i, ok := ctx.Value(interruptionKey{}).(*interruption); ok {
+		return []utask.ShellTaskOption{i.forward}
+	}
+	return nil
+}
+
+// signal which interrupted utask, 0 if none
+func interruptedBy(ctx context.Context) syscall.Signal {
+	if i, ok := ctx.Value(interruptionKey{}).(*interruption); ok {
+		return syscall.Signal(i.signal.Load())
+	}
+	return 0
+}
+
+// context relaying SIGINT and SIGTERM to tasks started with taskOptions.
+//   - the first signal is relayed, the context is canceled (with cause interrupted)
+//     only if tasks are still running after interruptGrace
+//   - a second signal terminates the process with the default handling,
+//     instead of waiting for tasks ignoring the signal
+func notifyContext(parent context.Context) (context.Context, func()) {
+	forwarder := utask.NewSignalForwarder(syscall.SIGINT, syscall.SIGTERM)
+	i := &interruption{forward: utask.WithShellSignalForwarder(forwarder)}
+	ctx, cancel := context.WithCancelCause(context.WithValue(parent, interruptionKey{}, i))
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	stop := func() {
+		forwarder.Stop()
+		signal.Stop(signals)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		var escalate <-chan time.Time
+		for {
+			select {
+			case sig := <-signals:
+				if i.signal.CompareAndSwap(0, int32(sig.(syscall.Signal))) {
+					timer := time.NewTimer(interruptGrace)
+					defer timer.Stop()
+					escalate = timer.C
+					continue
+				}
+				stop()
+				_ = syscall.Kill(os.Getpid(), sig.(syscall.Signal))
+				return
+			case <-escalate:
+				escalate = nil
+				cancel(interrupted{signal: interruptedBy(ctx)})
+			case <-stopped:
+				return
+			}
+		}
+	}()
+	return ctx, func() {
+		stop()
+		close(stopped)
+		cancel(nil)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	var commandFunc func(context.Context, []string, io.Writer, io.Writer) int
+	switch args[0] {
+	case "run":
+		commandFunc = runCommand
+	case "exec":
+		commandFunc = execCommand
+	case "list":
+		commandFunc = listCommand
+	case "graph":
+		commandFunc = graphCommand
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "utask: unknown command %q\n%s", args[0], usage)
+		return exitUsage
+	}
+	return commandFunc(ctx, args[1:], stdout, stderr)
+}
+
+// exit code for the error returned by a task or graph
+func exitCode(ctx context.Context, err error) int {
+	if sig := interruptedBy(ctx); sig != 0 {
+		return 128 + int(sig)
+	}
+	if err == nil {
+		return exitOK
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return exitTimeout
+	}
+	return exitFailure
+}
+
+// exit code of a single command, as reported by the shell
+func commandExitCode(ctx context.Context, err error) int {
+	code := exitCode(ctx, err)
+	if code != exitFailure {
+		return code
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return exitErr.ExitCode()
+	}
+	return exitFailure
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testFile = `tasks:
+  - name: generate
+    command: sh
+    args: [-c, "echo generating"]
+  - name: build
+    command: sh
+    args: [-c, "echo building"]
+    needs: [generate]
+  - name: broken
+    command: sh
+    args: [-c, "exit 3"]
+  - name: deploy
+    command: sh
+    args: [-c, "echo deploying"]
+    needs: [build, broken]
+`
+
+func writeTestFile(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "tasks.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testFile), 0o644))
+	return path
+}
+
+func runCLI(ctx context.Context, args ...string) (int, string, string) {
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+	code := run(ctx, args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunTargets(t *testing.T) {
+	code, _, stderr := runCLI(context.Background(), "run", writeTestFile(t), "build")
+	require.Equal(t, exitOK, code, stderr)
+	require.Contains(t, stderr, "utask: generate succeeded after")
+	require.Contains(t, stderr, "utask: build succeeded after")
+	require.NotContains(t, stderr, "deploy")
+}
+
+func TestRunFailure(t *testing.T) {
+	code, _, stderr := runCLI(context.Background(), "run", writeTestFile(t))
+	require.Equal(t, exitFailure, code)
+	require.Contains(t, stderr, "utask: broken failed after")
+	require.Contains(t, stderr, `utask: deploy skipped: graph: dependency "broken" failed`)
+	require.Contains(t, stderr, "utask: graph: 1 of 4 nodes failed (broken)\n")
+}
+
+func TestRunUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"unknown"},
+		{"run"},
+		{"run", "does-not-exist.yaml"},
+		{"run", writeTestFile(t), "nope"},
+		{"exec"},
+		{"exec", "-term-signal", "SIGNOPE", "--", "true"},
+		{"list"},
+	} {
+		code, _, _ := runCLI(context.Background(), args...)
+		require.Equal(t, exitUsage, code, args)
+	}
+}
+
+func TestExec(t *testing.T) {
+	code, stdout, _ := runCLI(context.Background(), "exec", "--", "sh", "-c", "echo hello; exit 7")
+	require.Equal(t, 7, code)
+	require.Equal(t, "hello\n", stdout)
+
+	code, _, stderr := runCLI(context.Background(), "exec", "-timeout", "50ms", "-wait-delay", "10ms", "--", "sleep", "10")
+	require.Equal(t, exitTimeout, code)
+	require.Contains(t, stderr, "utask: sleep timed out after 50ms")
+
+	code, _, _ = runCLI(context.Background(), "exec", "--", "does-not-exist-anywhere")
+	require.Equal(t, 127, code)
+}
+
+func TestExecInterrupted(t *testing.T) {
+	ctx, stop := notifyContext(context.Background())
+	defer stop()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = syscall.Kill(os.Getpid(), syscall.SIGINT)
+	}()
+	code, _, _ := runCLI(ctx, "exec", "--", "sleep", "10")
+	require.Equal(t, 128+int(syscall.SIGINT), code)
+}
+
+func TestExecRelaysSignal(t *testing.T) {
+	ctx, stop := notifyContext(context.Background())
+	defer stop()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = syscall.Kill(os.Getpid(), syscall.SIGINT)
+	}()
+	// the command receives SIGINT, not its term signal
+	code, stdout, _ := runCLI(ctx, "exec", "--", "sh", "-c", "trap 'echo got INT; kill $!; exit 5' INT; trap 'echo got TERM; exit 6' TERM; sleep 10 >/dev/null & wait")
+	require.Equal(t, 128+int(syscall.SIGINT), code)
+	require.Equal(t, "got INT\n", stdout)
+	require.NoError(t, ctx.Err())
+}
+
+func TestSecondSignalTerminates(t *testing.T) {
+	if os.Getenv("UTASK_TEST_MAIN") == "1" {
+		// arguments for utask follow the first "--"
+		for i, arg := range os.Args {
+			if arg == "--" {
+				os.Args = append([]string{"utask"}, os.Args[i+1:]...)
+				break
+			}
+		}
+		main()
+		return
+	}
+
+	// run utask in a subprocess: the command ignores the relayed signal and its term signal
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSecondSignalTerminates$", "--",
+		"exec", "-wait-delay", "10s", "--", "sh", "-c", "trap '' INT TERM; sleep 10")
+	cmd.Env = append(os.Environ(), "UTASK_TEST_MAIN=1")
+	require.NoError(t, cmd.Start())
+	time.Sleep(200 * time.Millisecond)
+	require.NoError(t, cmd.Process.Signal(syscall.SIGINT))
+	time.Sleep(200 * time.Millisecond)
+	require.NoError(t, cmd.Process.Signal(syscall.SIGINT))
+
+	start := time.Now()
+	err := cmd.Wait()
+	require.Less(t, time.Since(start), 5*time.Second)
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	status := exitErr.Sys().(syscall.WaitStatus)
+	require.True(t, status.Signaled())
+	require.Equal(t, syscall.SIGINT, status.Signal())
+}
+
+func TestList(t *testing.T) {
+	code, stdout, _ := runCLI(context.Background(), "list", writeTestFile(t))
+	require.Equal(t, exitOK, code)
+	require.Equal(t, `generate  sh -c echo generating
+build     sh -c echo building  (needs generate)
+broken    sh -c exit 3
+deploy    sh -c echo deploying  (needs build, broken)
+`, stdout)
+}
+
+func TestGraph(t *testing.T) {
+	code, stdout, _ := runCLI(context.Background(), "graph", writeTestFile(t))
+	require.Equal(t, exitOK, code)
+	require.Equal(t, `digraph utask {
+  "generate";
+  "build";
+  "broken";
+  "deploy";
+  "generate" -> "build";
+  "build" -> "deploy";
+  "broken" -> "deploy";
+}
+`, stdout)
+}
+
+func TestRunResume(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "fixed")
+	path := filepath.Join(dir, "tasks.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`tasks:
+  - name: prepare
+    command: sh
+    args: [-c, "true"]
+  - name: flaky
+    command: sh
+    args: [-c, "test -f `+marker+`"]
+    needs: [prepare]
+`), 0o644))
+	checkpoints := filepath.Join(dir, "checkpoints")
+
+	code, _, stderr := runCLI(context.Background(), "run", "-checkpoint", checkpoints, path)
+	require.Equal(t, exitFailure, code)
+	require.Contains(t, stderr, "utask: resume with -checkpoint "+checkpoints+" -resume ")
+
+	require.NoError(t, os.WriteFile(marker, nil, 0o644))
+	code, _, stderr = runCLI(context.Background(), "run", "-checkpoint", checkpoints, "-resume", "last", path)
+	require.Equal(t, exitOK, code, stderr)
+	require.Contains(t, stderr, "utask: prepare succeeded in a previous attempt of the run\n")
+	require.Contains(t, stderr, "utask: flaky succeeded after")
+
+	code, _, _ = runCLI(context.Background(), "run", "-resume", "last", path)
+	require.Equal(t, exitUsage, code)
+	code, _, _ = runCLI(context.Background(), "run", "-checkpoint", filepath.Join(dir, "empty"), "-resume", "last", path)
+	require.Equal(t, exitUsage, code)
+}
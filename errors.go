@@ -0,0 +1,25 @@
+package utask
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error returned by a task configured with WithShellStderrTail
+type TaskError struct {
+	// The original error (e.g. *exec.ExitError)
+	Err error
+	// Last lines written to stderr before the task failed
+	StderrTail []string
+}
+
+func (e *TaskError) Error() string {
+	if len(e.StderrTail) == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s\nstderr (last %d lines):\n  %s", e.Err, len(e.StderrTail), strings.Join(e.StderrTail, "\n  "))
+}
+
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
@@ -8,7 +8,8 @@ import (
 )
 
 type functionTask struct {
-	opts options[functionTaskOptions]
+	opts      options[functionTaskOptions]
+	lifecycle *lifecycle
 
 	stdout io.Writer
 	stderr io.Writer
@@ -34,19 +35,47 @@ func NewFunctionTask(opts ...FunctionTaskOption) (Task, error) {
 		mergedOpts.ctx = context.Background()
 	}
 
+	named := mergedOpts.name != ""
+	if !named {
+		mergedOpts.name = "function"
+	}
+
+	slogOut := attachSlog(&mergedOpts)
+	redact := redactOutput(&mergedOpts)
+	if slogOut != nil {
+		slogOut.redact = redact
+	}
+
+	description := "function"
+	if named {
+		description = redact(fmt.Sprintf("function '%s'", mergedOpts.name))
+	}
+
+	lifecycle, err := newLifecycle(mergedOpts, description)
+	if err != nil {
+		return nil, err
+	}
+
 	stdout := io.Discard
 	if mergedOpts.stdout != nil {
-		stdout = newNewLineWriter(mergedOpts.stdout)
+		stdout = mergedOpts.stdout
+		if !mergedOpts.specific.rawOutput {
+			stdout = newLineWriter(stdout)
+		}
 	}
 	stderr := io.Discard
 	if mergedOpts.stderr != nil {
-		stderr = newNewLineWriter(mergedOpts.stderr)
+		stderr = mergedOpts.stderr
+		if !mergedOpts.specific.rawOutput {
+			stderr = newLineWriter(stderr)
+		}
 	}
 
 	return &functionTask{
-		opts:   mergedOpts,
-		stdout: stdout,
-		stderr: stderr,
+		opts:      mergedOpts,
+		lifecycle: lifecycle,
+		stdout:    stdout,
+		stderr:    stderr,
 	}, nil
 }
 
@@ -64,12 +93,20 @@ func (t *functionTask) Run() error {
 // Can be run in a go-routine if asynchroneous execution is desired.
 func (t *functionTask) Start() error {
 	t.ret = make(chan error)
+	t.lifecycle.start()
+	t.lifecycle.running(0)
 
 	go func() {
 		err := t.opts.specific.fn(t.opts.ctx, t.stdout, t.stderr)
+
+		// partial lines are terminated once the function is done
+		flush(t.stdout)
+		flush(t.stderr)
+
 		if err != nil {
 			_, _ = t.stderr.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
 		}
+		t.lifecycle.end(err)
 		t.ret <- err
 	}()
 
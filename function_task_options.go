@@ -6,7 +6,8 @@ import (
 )
 
 type functionTaskOptions struct {
-	fn func(context.Context, io.Writer, io.Writer) error
+	fn        func(context.Context, io.Writer, io.Writer) error
+	rawOutput bool
 }
 
 // Options for a function task
@@ -16,9 +17,23 @@ type FunctionTaskOption taskOption[functionTaskOptions]
 // Usually used for timeouts
 var WithFunctionContext = withContext[functionTaskOptions]
 
+// Name of the task, available in lifecycle templates (default: "function")
+var WithFunctionName = withName[functionTaskOptions]
+
 // Print start and end of the function to stdout
 var WithFunctionPrintStartAndEndInOutput = withPrintStartAndEndInOutput[functionTaskOptions]
 
+// Templates for the banners printed by WithFunctionPrintStartAndEndInOutput (default: DefaultLifecycleTemplate)
+var WithFunctionLifecycleTemplate = withLifecycleTemplate[functionTaskOptions]
+
+// Function called when the task is running and when it ended (independent of
+// WithFunctionPrintStartAndEndInOutput). LifecycleStart is not emitted if the task could not be started.
+var WithFunctionLifecycleHook = withLifecycleHook[functionTaskOptions]
+
+// Log every line of stdout and stderr as well as lifecycle events to logger.
+// Records have the attributes task, stream, pid (shell tasks only) and run_id.
+var WithFunctionSlog = withSlog[functionTaskOptions]
+
 // Write stdout on the given writer (default: os.Discard)
 var WithFunctionStdout = withStdout[functionTaskOptions]
 
@@ -28,6 +43,22 @@ var WithFunctionStderr = withStderr[functionTaskOptions]
 // Write stdout and stderr on the given writer (default: os.Discard)
 var WithFunctionCombinedOutput = withCombinedOutput[functionTaskOptions]
 
+// Record stdout, stderr and lifecycle banners as separate streams into a single
+// ordered log (e.g. output.NewRecorder()). Implies WithFunctionPrintStartAndEndInOutput.
+var WithFunctionCombinedLog = withCombinedLog[functionTaskOptions]
+
+// Write stdout and stderr into a group of g (e.g. output.NewGrouper()) which is printed
+// as one block when the task ended, including the banners of WithFunctionPrintStartAndEndInOutput
+var WithFunctionGroupedOutput = withGroupedOutput[functionTaskOptions]
+
+// Mask the given values in all output and in String() (replaced by output.Redacted).
+// Base64 and URL encoded forms of the values are masked as well.
+var WithFunctionSecrets = withSecrets[functionTaskOptions]
+
+// Mask matches of the given patterns in all output and in String() (replaced by output.Redacted).
+// If a pattern contains capture groups, only the groups are masked (e.g. `token=(\S+)`).
+var WithFunctionSecretPatterns = withSecretPatterns[functionTaskOptions]
+
 // Function to be executed.
 //   - supplied context should be checked regularly
 //   - a running function cannot be cancelled from the "outside", thus it is imperative
@@ -39,3 +70,13 @@ func WithFunction(fn func(context.Context, io.Writer, io.Writer) error) Function
 		return nil
 	})
 }
+
+// Pass bytes written by the function on to stdout and stderr as they are.
+// By default output is line-buffered: only complete lines are passed on and
+// a trailing partial line is terminated with a newline once the function returns.
+func WithFunctionRawOutput() FunctionTaskOption {
+	return newFuncTaskOption(func(o *options[functionTaskOptions]) error {
+		o.specific.rawOutput = true
+		return nil
+	})
+}
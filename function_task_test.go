@@ -1,9 +1,14 @@
 package utask_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"log/slog"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -43,6 +48,148 @@ func TestFunctionError(t *testing.T) {
 	requireOutput(t, stderr, "TestErr", "fail on purpose")
 }
 
+func TestFunctionPrintStartAndEnd(t *testing.T) {
+	stdout := utask.NewOutput()
+	task, err := utask.NewFunctionTask(
+		utask.WithFunctionName("greeter"),
+		utask.WithFunctionPrintStartAndEndInOutput(),
+		utask.WithFunction(func(ctx context.Context, stdout io.Writer, stderr io.Writer) error {
+			_, err := stdout.Write([]byte("hallo"))
+			return err
+		}),
+		utask.WithFunctionStdout(stdout),
+	)
+	require.NoError(t, err)
+	require.NoError(t, task.Run())
+	requireOutput(t, stdout,
+		"Running function 'greeter'",
+		"hallo",
+		"Done executing",
+	)
+}
+
+func TestFunctionPrintStartAndEndFailure(t *testing.T) {
+	stdout := utask.NewOutput()
+	task, err := utask.NewFunctionTask(
+		utask.WithFunctionPrintStartAndEndInOutput(),
+		utask.WithFunction(func(ctx context.Context, stdout io.Writer, stderr io.Writer) error {
+			return errors.New("fail on purpose")
+		}),
+		utask.WithFunctionStdout(stdout),
+	)
+	require.NoError(t, err)
+	require.ErrorContains(t, task.Run(), "fail on purpose")
+	lines := stdout.Lines()
+	require.Len(t, lines, 2)
+	require.Equal(t, "Running function", lines[0])
+	require.Regexp(t, `^Failed after .+: fail on purpose$`, lines[1])
+}
+
+func TestFunctionLineBuffering(t *testing.T) {
+	err, stdout, stderr := runFunction(func(ctx context.Context, stdout io.Writer, stderr io.Writer) error {
+		_, _ = stdout.Write([]byte("foo"))
+		_, _ = stdout.Write([]byte("bar\n"))
+		_, _ = fmt.Fprintf(stdout, "line1\nline2\n")
+		_, _ = stderr.Write([]byte("partial"))
+		return errors.New("fail on purpose")
+	}, 2*time.Second)
+
+	require.ErrorContains(t, err, "fail on purpose")
+	require.Equal(t, "foobar\nline1\nline2\n", stdout.String())
+	require.Equal(t, "partial\nfail on purpose\n", stderr.String())
+}
+
+func TestFunctionLineBufferingConcurrent(t *testing.T) {
+	err, stdout, _ := runFunction(func(ctx context.Context, stdout io.Writer, stderr io.Writer) error {
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					_, _ = stdout.Write([]byte("a line\n"))
+				}
+			}()
+		}
+		wg.Wait()
+		return nil
+	}, 2*time.Second)
+
+	require.NoError(t, err)
+	lines := stdout.Lines()
+	require.Len(t, lines, 1000)
+	for _, line := range lines {
+		require.Equal(t, "a line", line)
+	}
+}
+
+func TestFunctionRawOutput(t *testing.T) {
+	stdout := utask.NewOutput()
+	task, err := utask.NewFunctionTask(
+		utask.WithFunctionRawOutput(),
+		utask.WithFunction(func(ctx context.Context, stdout io.Writer, stderr io.Writer) error {
+			_, _ = stdout.Write([]byte("foo"))
+			_, _ = stdout.Write([]byte("bar"))
+			return nil
+		}),
+		utask.WithFunctionStdout(stdout),
+	)
+	require.NoError(t, err)
+	require.NoError(t, task.Run())
+	require.Equal(t, "foobar", stdout.String())
+}
+
+func TestFunctionSecrets(t *testing.T) {
+	stdout := utask.NewOutput()
+	stderr := utask.NewOutput()
+	task, err := utask.NewFunctionTask(
+		utask.WithFunctionSecrets("topsecret"),
+		utask.WithFunction(func(ctx context.Context, stdout io.Writer, stderr io.Writer) error {
+			_, _ = stdout.Write([]byte("the token is top"))
+			_, _ = stdout.Write([]byte("secret"))
+			return errors.New("invalid token topsecret")
+		}),
+		utask.WithFunctionStdout(stdout),
+		utask.WithFunctionStderr(stderr),
+	)
+	require.NoError(t, err)
+	require.Error(t, task.Run())
+	requireOutput(t, stdout, "the token is ***")
+	requireOutput(t, stderr, "invalid token ***")
+}
+
+func TestFunctionSlog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" || a.Key == "run_id" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	task, err := utask.NewFunctionTask(
+		utask.WithFunctionContext(ctx),
+		utask.WithFunctionSlog(logger),
+		utask.WithFunction(func(ctx context.Context, stdout io.Writer, stderr io.Writer) error {
+			_, _ = fmt.Fprintf(stdout, "waiting")
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+	)
+	require.NoError(t, err)
+	require.Error(t, task.Run())
+	require.Equal(t, strings.Join([]string{
+		`level=INFO msg="task started" task=function description=function`,
+		`level=INFO msg=waiting task=function stream=stdout`,
+		`level=INFO msg="context deadline exceeded" task=function stream=stderr`,
+		`level=WARN msg="task failed" task=function description=function exit_code=-1 error="context deadline exceeded"`,
+	}, "\n")+"\n", buf.String())
+}
+
 func TestFunctionTimeoutSuccess(t *testing.T) {
 	err, stdout, stderr := runFunction(func(ctx context.Context, stdout io.Writer, stderr io.Writer) error {
 		select {
@@ -82,7 +229,7 @@ func TestFunctionTaskCancel(t *testing.T) {
 		start := time.Now()
 		for {
 			// output
-			_, _ = stdout.Write([]byte("running"))
+			_, _ = stdout.Write([]byte("running\n"))
 
 			// if function gets 2 seconds to run: exit with success
 			if time.Since(start) > 2*time.Second {
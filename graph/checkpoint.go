@@ -0,0 +1,106 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// State of a graph run persisted by WithCheckpoint, stored in <dir>/<run-id>.json
+type Checkpoint struct {
+	RunID   string                    `json:"runId"`
+	Started time.Time                 `json:"started"`
+	Updated time.Time                 `json:"updated"`
+	Nodes   map[string]NodeCheckpoint `json:"nodes"`
+}
+
+// Persisted result of a single node
+type NodeCheckpoint struct {
+	Status Status `json:"status"`
+	// Hash of the node's spec (see WithSpec) at the time it ran
+	SpecHash string    `json:"specHash,omitempty"`
+	Attempts int       `json:"attempts,omitempty"`
+	Err      string    `json:"err,omitempty"`
+	Started  time.Time `json:"started,omitempty"`
+	Ended    time.Time `json:"ended,omitempty"`
+}
+
+// Read the checkpoint of a run
+func ReadCheckpoint(dir string, runID string) (*Checkpoint, error) {
+	if runID == "" || strings.ContainsAny(runID, `/\`) {
+		return nil, fmt.Errorf("graph: invalid run id %q", runID)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, runID+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("graph: unknown run %q", runID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &Checkpoint{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("graph: invalid checkpoint of run %q: %w", runID, err)
+	}
+	if c.Nodes == nil {
+		c.Nodes = map[string]NodeCheckpoint{}
+	}
+	return c, nil
+}
+
+// Ids of all runs with a checkpoint in dir, oldest first
+func ListCheckpoints(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, e := range entries {
+		if name := e.Name(); !e.IsDir() && strings.HasSuffix(name, ".json") {
+			ids = append(ids, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	// run ids are sortable by creation time
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// write the checkpoint atomically
+func (c *Checkpoint) write(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	c.Updated = time.Now()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, c.RunID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if err := errors.Join(err, tmp.Sync(), tmp.Close()); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, c.RunID+".json"))
+}
+
+func specHash(spec []byte) string {
+	if spec == nil {
+		return ""
+	}
+	sum := sha256.Sum256(spec)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,122 @@
+package graph_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dunv/utask/graph"
+	"github.com/stretchr/testify/require"
+)
+
+// pipeline a -> b -> c and an independent d, b fails unless fixed
+func pipeline(t *testing.T, dir string, r *recorder, bFixed bool, aSpec string) graph.Graph {
+	var bErr error
+	if !bFixed {
+		bErr = errors.New("broken")
+	}
+	g := graph.New(graph.WithCheckpoint(dir), graph.WithNodeHook(r.hook))
+	require.NoError(t, g.Add("a", r.task("a", nil), graph.WithSpec([]byte(aSpec))))
+	require.NoError(t, g.Add("b", r.task("b", bErr), graph.WithNeeds("a"), graph.WithSpec([]byte("b"))))
+	require.NoError(t, g.Add("c", r.task("c", nil), graph.WithNeeds("b")))
+	require.NoError(t, g.Add("d", r.task("d", nil)))
+	return g
+}
+
+func TestGraphResume(t *testing.T) {
+	dir := t.TempDir()
+
+	first := newRecorder()
+	require.Error(t, pipeline(t, dir, first, false, "a").Run(context.Background()))
+	runID := first.results["a"].RunID
+	require.NotEmpty(t, runID)
+
+	ids, err := graph.ListCheckpoints(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{runID}, ids)
+
+	c, err := graph.ReadCheckpoint(dir, runID)
+	require.NoError(t, err)
+	require.Equal(t, graph.StatusSucceeded, c.Nodes["a"].Status)
+	require.Equal(t, graph.StatusFailed, c.Nodes["b"].Status)
+	require.Equal(t, "broken", c.Nodes["b"].Err)
+	require.Equal(t, graph.StatusSkipped, c.Nodes["c"].Status)
+
+	// only failed and skipped nodes run again
+	second := newRecorder()
+	require.NoError(t, pipeline(t, dir, second, true, "a").Resume(context.Background(), runID))
+	require.ElementsMatch(t, []string{"b", "c"}, second.order)
+	require.True(t, second.results["a"].Resumed)
+	require.True(t, second.results["d"].Resumed)
+	require.Equal(t, runID, second.results["c"].RunID)
+
+	c, err = graph.ReadCheckpoint(dir, runID)
+	require.NoError(t, err)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		require.Equal(t, graph.StatusSucceeded, c.Nodes[name].Status, name)
+	}
+
+	// a changed spec invalidates the node and all nodes depending on it
+	third := newRecorder()
+	require.NoError(t, pipeline(t, dir, third, true, "a changed").Resume(context.Background(), runID))
+	require.ElementsMatch(t, []string{"a", "b", "c"}, third.order)
+	require.True(t, third.results["d"].Resumed)
+}
+
+func TestGraphResumeSubset(t *testing.T) {
+	dir := t.TempDir()
+
+	first := newRecorder()
+	require.Error(t, pipeline(t, dir, first, false, "a").Run(context.Background()))
+	runID := first.results["a"].RunID
+
+	// resume with only some of the nodes, the others keep their results
+	second := newRecorder()
+	g := graph.New(graph.WithCheckpoint(dir), graph.WithNodeHook(second.hook))
+	require.NoError(t, g.Add("a", second.task("a", nil), graph.WithSpec([]byte("a"))))
+	require.NoError(t, g.Add("b", second.task("b", nil), graph.WithNeeds("a"), graph.WithSpec([]byte("b"))))
+	require.NoError(t, g.Resume(context.Background(), runID))
+	require.Equal(t, []string{"b"}, second.order)
+
+	c, err := graph.ReadCheckpoint(dir, runID)
+	require.NoError(t, err)
+	require.Equal(t, graph.StatusSucceeded, c.Nodes["b"].Status)
+	require.Equal(t, graph.StatusSucceeded, c.Nodes["d"].Status)
+	require.Equal(t, graph.StatusSkipped, c.Nodes["c"].Status)
+
+	// the full graph reuses all of them
+	third := newRecorder()
+	require.NoError(t, pipeline(t, dir, third, true, "a").Resume(context.Background(), runID))
+	require.Equal(t, []string{"c"}, third.order)
+	require.True(t, third.results["d"].Resumed)
+}
+
+func TestGraphResumeCanceled(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r := newRecorder()
+	g := graph.New(graph.WithCheckpoint(dir), graph.WithNodeHook(r.hook), graph.WithParallelism(1))
+	require.NoError(t, g.Add("a", fn(func(ctx context.Context) error {
+		cancel()
+		return nil
+	})))
+	require.NoError(t, g.Add("b", r.task("b", nil), graph.WithNeeds("a")))
+	require.ErrorIs(t, g.Run(ctx), context.Canceled)
+	require.Empty(t, r.order)
+
+	ids, err := graph.ListCheckpoints(dir)
+	require.NoError(t, err)
+	require.Len(t, ids, 1)
+	require.NoError(t, g.Resume(context.Background(), ids[0]))
+	require.Equal(t, []string{"b"}, r.order)
+}
+
+func TestGraphResumeErrors(t *testing.T) {
+	g := graph.New()
+	require.EqualError(t, g.Resume(context.Background(), "x"), "graph: resuming requires WithCheckpoint")
+
+	g = graph.New(graph.WithCheckpoint(t.TempDir()))
+	require.EqualError(t, g.Resume(context.Background(), "x"), `graph: unknown run "x"`)
+	require.EqualError(t, g.Resume(context.Background(), "../x"), `graph: invalid run id "../x"`)
+}
@@ -0,0 +1,378 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dunv/utask"
+	"github.com/dunv/utask/output"
+)
+
+// State of a node after a run of the graph
+type Status string
+
+const (
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	// Not run because a dependency did not succeed or the run was canceled
+	StatusSkipped Status = "skipped"
+)
+
+// Outcome of a single node
+type NodeResult struct {
+	Name string
+	// Identifier of the graph run (see WithCheckpoint)
+	RunID  string
+	Status Status
+	// True if the node was not run again because it succeeded in the resumed run (see Resume)
+	Resumed bool
+	// Number of times the task was run (0 if skipped)
+	Attempts int
+	// Error of the last attempt
+	Err     error
+	Started time.Time
+	Ended   time.Time
+}
+
+// Static description of a node
+type NodeInfo struct {
+	Name    string
+	Needs   []string
+	Retries int
+	Timeout time.Duration
+}
+
+// Tasks with dependencies between them
+//   - a node runs once all nodes it needs succeeded, independent nodes run in parallel
+//   - nodes depending on a failed node are skipped, all other nodes still run
+//   - every attempt gets a fresh task from the node's factory
+type Graph interface {
+	// Add a node running the tasks created by factory. Dependencies (see WithNeeds)
+	// may be added later, they are checked by Validate and Run.
+	Add(name string, factory utask.TaskFactory, opts ...NodeOption) error
+	// All nodes in topological order (nodes before the nodes needing them, otherwise in the order they were added)
+	Nodes() ([]NodeInfo, error)
+	// Check for unknown dependencies and cycles
+	Validate() error
+	// Run all nodes and wait for them. Returns an error listing all failed nodes,
+	// ctx.Err() if the run was canceled.
+	Run(ctx context.Context) error
+	// Continue a run persisted by WithCheckpoint. Nodes which succeeded are not run again,
+	// unless their spec changed (see WithSpec) or a node they need is run again.
+	// Results of nodes which are not added to this graph are kept in the checkpoint.
+	Resume(ctx context.Context, runID string) error
+}
+
+type graph struct {
+	mu     sync.Mutex
+	hookMu sync.Mutex
+	opts   graphOptions
+	nodes  map[string]*node
+	order  []string
+}
+
+type node struct {
+	name    string
+	factory utask.TaskFactory
+	opts    nodeOptions
+}
+
+// Create an empty graph
+func New(opts ...Option) Graph {
+	mergedOpts := graphOptions{}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+	return &graph{opts: mergedOpts, nodes: map[string]*node{}}
+}
+
+func (g *graph) Add(name string, factory utask.TaskFactory, opts ...NodeOption) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if name == "" {
+		return errors.New("graph: node without name")
+	}
+	if _, ok := g.nodes[name]; ok {
+		return fmt.Errorf("graph: duplicate node %q", name)
+	}
+	if factory == nil {
+		return fmt.Errorf("graph: no factory given for node %q", name)
+	}
+
+	mergedOpts := nodeOptions{}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+	g.nodes[name] = &node{name: name, factory: factory, opts: mergedOpts}
+	g.order = append(g.order, name)
+	return nil
+}
+
+func (g *graph) Nodes() ([]NodeInfo, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	order, err := g.sortLocked()
+	if err != nil {
+		return nil, err
+	}
+	infos := []NodeInfo{}
+	for _, name := range order {
+		n := g.nodes[name]
+		infos = append(infos, NodeInfo{
+			Name:    name,
+			Needs:   append([]string{}, n.opts.needs...),
+			Retries: n.opts.retries,
+			Timeout: n.opts.timeout,
+		})
+	}
+	return infos, nil
+}
+
+func (g *graph) Validate() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, err := g.sortLocked()
+	return err
+}
+
+func (g *graph) sortLocked() ([]string, error) {
+	for _, name := range g.order {
+		for _, need := range g.nodes[name].opts.needs {
+			if _, ok := g.nodes[need]; !ok {
+				return nil, fmt.Errorf("graph: node %q needs unknown node %q", name, need)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	order := []string{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("graph: dependency cycle %s", strings.Join(append(path, name), " -> "))
+		}
+		state[name] = visiting
+		for _, need := range g.nodes[name].opts.needs {
+			if err := visit(need, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range g.order {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func (g *graph) Run(ctx context.Context) error {
+	return g.run(ctx, output.NewRunID(), nil)
+}
+
+func (g *graph) Resume(ctx context.Context, runID string) error {
+	if g.opts.checkpointDir == "" {
+		return errors.New("graph: resuming requires WithCheckpoint")
+	}
+	previous, err := ReadCheckpoint(g.opts.checkpointDir, runID)
+	if err != nil {
+		return err
+	}
+	return g.run(ctx, runID, previous)
+}
+
+func (g *graph) run(ctx context.Context, runID string, previous *Checkpoint) error {
+	g.mu.Lock()
+	order, err := g.sortLocked()
+	nodes := g.nodes
+	g.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// nodes which succeeded before with the same spec and whose dependencies are all reused
+	reused := map[string]bool{}
+	if previous != nil {
+		for _, name := range order {
+			n := nodes[name]
+			before, ok := previous.Nodes[name]
+			reuse := ok && before.Status == StatusSucceeded && before.SpecHash == specHash(n.opts.spec)
+			for _, need := range n.opts.needs {
+				reuse = reuse && reused[need]
+			}
+			reused[name] = reuse
+		}
+	}
+
+	// guarded by hookMu
+	checkpoint := &Checkpoint{RunID: runID, Started: time.Now(), Nodes: map[string]NodeCheckpoint{}}
+	if previous != nil {
+		// nodes which are not part of this graph (anymore) keep their previous result
+		checkpoint.Started = previous.Started
+		for name, c := range previous.Nodes {
+			checkpoint.Nodes[name] = c
+		}
+	}
+	if err := g.saveCheckpoint(checkpoint); err != nil {
+		return err
+	}
+
+	var sem chan struct{}
+	if g.opts.parallelism > 0 {
+		sem = make(chan struct{}, g.opts.parallelism)
+	}
+
+	results := map[string]*NodeResult{}
+	done := map[string]chan struct{}{}
+	for _, name := range order {
+		results[name] = &NodeResult{Name: name, RunID: runID}
+		done[name] = make(chan struct{})
+	}
+
+	wg := sync.WaitGroup{}
+	for _, name := range order {
+		wg.Add(1)
+		go func(n *node) {
+			defer wg.Done()
+			defer close(done[n.name])
+			result := results[n.name]
+
+			if reused[n.name] {
+				before := previous.Nodes[n.name]
+				result.Status = StatusSucceeded
+				result.Resumed = true
+				result.Attempts = before.Attempts
+				result.Started = before.Started
+				result.Ended = before.Ended
+				g.report(checkpoint, *result, n)
+				return
+			}
+
+			for _, need := range n.opts.needs {
+				<-done[need]
+				if results[need].Status != StatusSucceeded {
+					result.Status = StatusSkipped
+					result.Err = fmt.Errorf("graph: dependency %q %s", need, results[need].Status)
+					g.report(checkpoint, *result, n)
+					return
+				}
+			}
+
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-ctx.Done():
+				}
+			}
+			if ctx.Err() != nil {
+				result.Status = StatusSkipped
+				result.Err = ctx.Err()
+				g.report(checkpoint, *result, n)
+				return
+			}
+
+			result.Started = time.Now()
+			for result.Attempts <= n.opts.retries {
+				result.Attempts++
+				result.Err = g.attempt(ctx, n, result.Attempts)
+				if result.Err == nil || ctx.Err() != nil {
+					break
+				}
+			}
+			result.Ended = time.Now()
+			result.Status = StatusSucceeded
+			if result.Err != nil {
+				result.Status = StatusFailed
+			}
+			g.report(checkpoint, *result, n)
+		}(nodes[name])
+	}
+	wg.Wait()
+
+	if err := g.saveCheckpoint(checkpoint); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	failed := []string{}
+	errs := []error{}
+	for _, name := range order {
+		if results[name].Status == StatusFailed {
+			failed = append(failed, name)
+			errs = append(errs, fmt.Errorf("%s: %w", name, results[name].Err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("graph: %d of %d nodes failed (%s)\n%w", len(failed), len(order), strings.Join(failed, ", "), errors.Join(errs...))
+	}
+	return nil
+}
+
+func (g *graph) attempt(ctx context.Context, n *node, attempt int) error {
+	ctx = utask.ContextWithAttempt(ctx, attempt)
+	if n.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.opts.timeout)
+		defer cancel()
+	}
+	task, err := n.factory(ctx)
+	if err != nil {
+		return err
+	}
+	return task.Run()
+}
+
+// record the result in the checkpoint and pass it to the hook
+func (g *graph) report(checkpoint *Checkpoint, r NodeResult, n *node) {
+	g.hookMu.Lock()
+	defer g.hookMu.Unlock()
+
+	c := NodeCheckpoint{
+		Status:   r.Status,
+		SpecHash: specHash(n.opts.spec),
+		Attempts: r.Attempts,
+		Started:  r.Started,
+		Ended:    r.Ended,
+	}
+	if r.Err != nil {
+		c.Err = r.Err.Error()
+	}
+	checkpoint.Nodes[r.Name] = c
+	if g.opts.checkpointDir != "" {
+		// the checkpoint is written again at the end of the run, so a failure here is not fatal
+		_ = checkpoint.write(g.opts.checkpointDir)
+	}
+
+	if g.opts.nodeHook != nil {
+		g.opts.nodeHook(r)
+	}
+}
+
+func (g *graph) saveCheckpoint(checkpoint *Checkpoint) error {
+	if g.opts.checkpointDir == "" {
+		return nil
+	}
+	g.hookMu.Lock()
+	defer g.hookMu.Unlock()
+	return checkpoint.write(g.opts.checkpointDir)
+}
@@ -0,0 +1,105 @@
+package graph
+
+import "time"
+
+type graphOptions struct {
+	parallelism   int
+	nodeHook      func(NodeResult)
+	checkpointDir string
+}
+
+// Options for a graph
+type Option interface {
+	apply(*graphOptions)
+}
+
+type funcOption struct {
+	f func(*graphOptions)
+}
+
+// nolint:unused
+func (fo *funcOption) apply(o *graphOptions) {
+	fo.f(o)
+}
+
+func newFuncOption(f func(*graphOptions)) *funcOption {
+	return &funcOption{f: f}
+}
+
+// Maximum number of nodes running at the same time (default: 0, unlimited)
+func WithParallelism(n int) Option {
+	return newFuncOption(func(o *graphOptions) {
+		o.parallelism = n
+	})
+}
+
+// Function called once for every node when it succeeded, failed or was skipped.
+// Calls are never concurrent.
+func WithNodeHook(hook func(NodeResult)) Option {
+	return newFuncOption(func(o *graphOptions) {
+		o.nodeHook = hook
+	})
+}
+
+// Persist the result of every node to <dir>/<run-id>.json as soon as it is known,
+// so a failed or interrupted run can be continued with Resume (see ListCheckpoints).
+// The run id is part of every NodeResult.
+func WithCheckpoint(dir string) Option {
+	return newFuncOption(func(o *graphOptions) {
+		o.checkpointDir = dir
+	})
+}
+
+type nodeOptions struct {
+	needs   []string
+	retries int
+	timeout time.Duration
+	spec    []byte
+}
+
+// Options for a single node of a graph
+type NodeOption interface {
+	apply(*nodeOptions)
+}
+
+type funcNodeOption struct {
+	f func(*nodeOptions)
+}
+
+// nolint:unused
+func (fno *funcNodeOption) apply(o *nodeOptions) {
+	fno.f(o)
+}
+
+func newFuncNodeOption(f func(*nodeOptions)) *funcNodeOption {
+	return &funcNodeOption{f: f}
+}
+
+// Nodes which have to succeed before this node runs
+func WithNeeds(names ...string) NodeOption {
+	return newFuncNodeOption(func(o *nodeOptions) {
+		o.needs = append(o.needs, names...)
+	})
+}
+
+// Run the task again up to n times if it failed (default: 0)
+func WithRetries(n int) NodeOption {
+	return newFuncNodeOption(func(o *nodeOptions) {
+		o.retries = n
+	})
+}
+
+// Cancel an attempt (via the context supplied to the factory) after d (default: 0, no timeout)
+func WithTimeout(d time.Duration) NodeOption {
+	return newFuncNodeOption(func(o *nodeOptions) {
+		o.timeout = d
+	})
+}
+
+// Description of what the node runs (e.g. a serialized task definition).
+// When resuming a run, a node is run again if its spec changed.
+func WithSpec(spec []byte) NodeOption {
+	return newFuncNodeOption(func(o *nodeOptions) {
+		o.spec = spec
+	})
+}
@@ -0,0 +1,123 @@
+package graph_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dunv/utask"
+	"github.com/dunv/utask/graph"
+	"github.com/stretchr/testify/require"
+)
+
+func fn(f func(ctx context.Context) error) utask.TaskFactory {
+	return func(ctx context.Context) (utask.Task, error) {
+		return utask.NewFunctionTask(
+			utask.WithFunctionContext(ctx),
+			utask.WithFunction(func(ctx context.Context, stdout, stderr io.Writer) error {
+				return f(ctx)
+			}),
+		)
+	}
+}
+
+type recorder struct {
+	mu      sync.Mutex
+	order   []string
+	results map[string]graph.NodeResult
+}
+
+func newRecorder() *recorder {
+	return &recorder{results: map[string]graph.NodeResult{}}
+}
+
+func (r *recorder) task(name string, err error) utask.TaskFactory {
+	return fn(func(ctx context.Context) error {
+		r.mu.Lock()
+		r.order = append(r.order, name)
+		r.mu.Unlock()
+		return err
+	})
+}
+
+func (r *recorder) hook(res graph.NodeResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results[res.Name] = res
+}
+
+func TestGraphOrder(t *testing.T) {
+	r := newRecorder()
+	g := graph.New(graph.WithParallelism(1), graph.WithNodeHook(r.hook))
+	require.NoError(t, g.Add("deploy", r.task("deploy", nil), graph.WithNeeds("build", "test")))
+	require.NoError(t, g.Add("test", r.task("test", nil), graph.WithNeeds("build")))
+	require.NoError(t, g.Add("build", r.task("build", nil)))
+
+	nodes, err := g.Nodes()
+	require.NoError(t, err)
+	require.Equal(t, "build", nodes[0].Name)
+	require.Equal(t, "test", nodes[1].Name)
+	require.Equal(t, []string{"build", "test"}, nodes[2].Needs)
+
+	require.NoError(t, g.Run(context.Background()))
+	require.Equal(t, []string{"build", "test", "deploy"}, r.order)
+	require.Equal(t, graph.StatusSucceeded, r.results["deploy"].Status)
+}
+
+func TestGraphFailureSkipsDependents(t *testing.T) {
+	r := newRecorder()
+	g := graph.New(graph.WithNodeHook(r.hook))
+	require.NoError(t, g.Add("build", r.task("build", errors.New("compile error"))))
+	require.NoError(t, g.Add("test", r.task("test", nil), graph.WithNeeds("build")))
+	require.NoError(t, g.Add("lint", r.task("lint", nil)))
+
+	err := g.Run(context.Background())
+	require.EqualError(t, err, "graph: 1 of 3 nodes failed (build)\nbuild: compile error")
+	require.Equal(t, graph.StatusFailed, r.results["build"].Status)
+	require.Equal(t, graph.StatusSkipped, r.results["test"].Status)
+	require.Equal(t, graph.StatusSucceeded, r.results["lint"].Status)
+	require.NotContains(t, r.order, "test")
+}
+
+func TestGraphRetries(t *testing.T) {
+	var attempts atomic.Int32
+	r := newRecorder()
+	g := graph.New(graph.WithNodeHook(r.hook))
+	require.NoError(t, g.Add("flaky", fn(func(ctx context.Context) error {
+		if n := attempts.Add(1); int(n) != utask.AttemptFromContext(ctx) {
+			return errors.New("attempt not passed on")
+		}
+		if attempts.Load() < 3 {
+			return errors.New("flaky")
+		}
+		return nil
+	}), graph.WithRetries(2)))
+	require.NoError(t, g.Run(context.Background()))
+	require.Equal(t, 3, r.results["flaky"].Attempts)
+}
+
+func TestGraphTimeout(t *testing.T) {
+	r := newRecorder()
+	g := graph.New(graph.WithNodeHook(r.hook))
+	require.NoError(t, g.Add("slow", fn(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), graph.WithTimeout(10*time.Millisecond), graph.WithRetries(1)))
+	require.ErrorIs(t, g.Run(context.Background()), context.DeadlineExceeded)
+	require.Equal(t, 2, r.results["slow"].Attempts)
+}
+
+func TestGraphValidate(t *testing.T) {
+	g := graph.New()
+	require.NoError(t, g.Add("a", fn(nil), graph.WithNeeds("b")))
+	require.EqualError(t, g.Validate(), `graph: node "a" needs unknown node "b"`)
+	require.NoError(t, g.Add("b", fn(nil), graph.WithNeeds("c")))
+	require.NoError(t, g.Add("c", fn(nil), graph.WithNeeds("a")))
+	require.EqualError(t, g.Validate(), "graph: dependency cycle a -> b -> c -> a")
+	require.Error(t, g.Run(context.Background()))
+	require.Error(t, g.Add("a", fn(nil)))
+}
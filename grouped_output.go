@@ -0,0 +1,49 @@
+package utask
+
+import (
+	"io"
+	"sync"
+
+	"github.com/dunv/utask/output"
+)
+
+// Destination printing the output of every run of a task as one block once the
+// task ended, e.g. output.NewGrouper()
+type Grouper interface {
+	TaskGroup(name string) output.GroupWriter
+}
+
+// stdout and stderr of a task using WithShellGroupedOutput/WithFunctionGroupedOutput,
+// a new group is started when the task starts and ended after its end banner
+type groupedOutput struct {
+	mu      sync.Mutex
+	grouper Grouper
+	group   output.GroupWriter
+}
+
+func (g *groupedOutput) begin(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.group = g.grouper.TaskGroup(name)
+}
+
+func (g *groupedOutput) Write(p []byte) (n int, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.group == nil {
+		return io.Discard.Write(p)
+	}
+	return g.group.Write(p)
+}
+
+func (g *groupedOutput) end(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.group != nil {
+		_ = g.group.End(err)
+		g.group = nil
+	}
+}
@@ -0,0 +1,222 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Store appending runs as JSON lines to a single file
+//   - Record, Query and Compact take a lock on path+".lock", so multiple processes can share the file
+//   - a partially written last line (e.g. after a crash) is ignored and removed by the next Record,
+//     a complete last line missing only its newline is kept
+//   - Query reads the whole file
+//   - Compact rewrites the file according to the retention options
+//   - safe for concurrent use
+type fileStore struct {
+	mu   sync.Mutex
+	path string
+	opts fileStoreOptions
+}
+
+// Create a store persisting to path, the file (and its directory) are created on first use
+func NewFileStore(path string, opts ...FileStoreOption) *fileStore {
+	mergedOpts := fileStoreOptions{}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+	return &fileStore{path: path, opts: mergedOpts}
+}
+
+func (s *fileStore) Record(run Run) error {
+	line, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := s.lock(syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := completeLastLine(f); err != nil {
+		return errors.Join(err, f.Close())
+	}
+	_, err = f.Write(append(line, '\n'))
+	return errors.Join(err, f.Close())
+}
+
+// complete the last line if it is missing its newline: a line which parses is kept
+// (as Query returns it), a partially written one (e.g. after a crash) is removed
+func completeLastLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil || last[0] == '\n' {
+		return err
+	}
+
+	// scan backwards for the end of the previous line
+	start := int64(0)
+	buf := make([]byte, 4096)
+	for end := info.Size(); end > 0; end -= int64(len(buf)) {
+		n := min(end, int64(len(buf)))
+		if _, err := f.ReadAt(buf[:n], end-n); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			start = end - n + int64(i) + 1
+			break
+		}
+	}
+
+	tail := make([]byte, info.Size()-start)
+	if _, err := f.ReadAt(tail, start); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(tail, &Run{}); err == nil {
+		_, err := f.Write([]byte{'\n'})
+		return err
+	}
+	return f.Truncate(start)
+}
+
+func (s *fileStore) Query(q Query) ([]Run, error) {
+	unlock, err := s.lock(syscall.LOCK_SH)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	runs, err := s.readLocked()
+	if err != nil {
+		return nil, err
+	}
+	return q.apply(runs), nil
+}
+
+// Remove runs according to the retention options (see WithRetainRuns, WithRetainAge),
+// returns the number of removed runs
+func (s *fileStore) Compact() (int, error) {
+	unlock, err := s.lock(syscall.LOCK_EX)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	runs, err := s.readLocked()
+	if err != nil {
+		return 0, err
+	}
+
+	// newest first per task, so the first retainRuns are kept
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Start.After(runs[j].Start)
+	})
+	kept := []Run{}
+	perTask := map[string]int{}
+	for _, run := range runs {
+		tooMany := s.opts.retainRuns > 0 && perTask[run.Task] >= s.opts.retainRuns
+		tooOld := s.opts.retainAge > 0 && time.Since(run.Start) > s.opts.retainAge
+		if tooMany || tooOld {
+			continue
+		}
+		perTask[run.Task]++
+		kept = append(kept, run)
+	}
+	removed := len(runs) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+
+	// oldest first again, like records are appended
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].Start.Before(kept[j].Start)
+	})
+
+	// rewrite the file atomically
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	enc := json.NewEncoder(f)
+	var encErr error
+	for _, run := range kept {
+		if encErr = enc.Encode(run); encErr != nil {
+			break
+		}
+	}
+	if err := errors.Join(encErr, f.Sync(), f.Close()); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return removed, os.Rename(tmp, s.path)
+}
+
+// lock the store within this process (mu) and across processes (flock on the lock file),
+// how is syscall.LOCK_EX or syscall.LOCK_SH
+func (s *fileStore) lock(how int) (func(), error) {
+	s.mu.Lock()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	// a separate lock file, the data file is replaced by Compact
+	f, err := os.OpenFile(s.path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		s.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		// closing the file releases the lock
+		f.Close()
+		s.mu.Unlock()
+	}, nil
+}
+
+// read all runs, a missing file means no runs
+func (s *fileStore) readLocked() ([]Run, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Run{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []Run{}
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		run := Run{}
+		if err := json.Unmarshal(line, &run); err != nil {
+			// a partially written last line (e.g. after a crash) is ignored
+			if i == len(lines)-1 {
+				break
+			}
+			return nil, fmt.Errorf("history: %s:%d: %w", s.path, i+1, err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
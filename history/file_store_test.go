@@ -0,0 +1,156 @@
+package history_test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dunv/utask/history"
+	"github.com/stretchr/testify/require"
+)
+
+func seed(t *testing.T, store interface{ Record(history.Run) error }, now time.Time) {
+	for i, run := range []history.Run{
+		{ID: "1", Task: "backup", Status: history.StatusSucceeded, Labels: map[string]string{"env": "prod"}},
+		{ID: "2", Task: "backup", Status: history.StatusFailed, Labels: map[string]string{"env": "prod"}},
+		{ID: "3", Task: "backup", Status: history.StatusSucceeded, Labels: map[string]string{"env": "staging"}},
+		{ID: "4", Task: "cleanup", Status: history.StatusSucceeded},
+		{ID: "5", Task: "backup", Status: history.StatusSucceeded, Labels: map[string]string{"env": "prod"}},
+	} {
+		run.Start = now.Add(time.Duration(i-5) * time.Hour)
+		run.End = run.Start.Add(time.Minute)
+		require.NoError(t, store.Record(run))
+	}
+}
+
+func ids(runs []history.Run) []string {
+	ids := []string{}
+	for _, r := range runs {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func TestFileStoreQuery(t *testing.T) {
+	now := time.Now().UTC()
+	store := history.NewFileStore(filepath.Join(t.TempDir(), "nested", "history.jsonl"))
+	seed(t, store, now)
+
+	for _, tc := range []struct {
+		query    history.Query
+		expected []string
+	}{
+		{history.Query{}, []string{"5", "4", "3", "2", "1"}},
+		{history.Query{Task: "backup", Status: history.StatusSucceeded}, []string{"5", "3", "1"}},
+		{history.Query{Labels: map[string]string{"env": "prod"}}, []string{"5", "2", "1"}},
+		{history.Query{Since: now.Add(-4 * time.Hour), Until: now.Add(-2 * time.Hour)}, []string{"3", "2"}},
+		{history.Query{Task: "backup", Limit: 2}, []string{"5", "3"}},
+		{history.Query{Task: "unknown"}, []string{}},
+	} {
+		runs, err := store.Query(tc.query)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, ids(runs), tc.query)
+	}
+}
+
+func TestFileStoreCompact(t *testing.T) {
+	now := time.Now().UTC()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store := history.NewFileStore(path, history.WithRetainRuns(2), history.WithRetainAge(4*time.Hour+30*time.Minute))
+	seed(t, store, now)
+
+	removed, err := store.Compact()
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+
+	runs, err := store.Query(history.Query{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"5", "4", "3"}, ids(runs))
+
+	// compacted file can still be appended to
+	require.NoError(t, store.Record(history.Run{ID: "6", Task: "backup", Start: now}))
+	runs, err = store.Query(history.Query{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"6", "5", "4", "3"}, ids(runs))
+
+	removed, err = history.NewFileStore(path).Compact()
+	require.NoError(t, err)
+	require.Equal(t, 0, removed)
+}
+
+func TestFileStorePartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store := history.NewFileStore(path)
+	require.NoError(t, store.Record(history.Run{ID: "1", Task: "backup"}))
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"id":"2","ta`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	runs, err := store.Query(history.Query{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"1"}, ids(runs))
+
+	// the partial line is removed before the next run is appended
+	require.NoError(t, store.Record(history.Run{ID: "3", Task: "backup"}))
+	runs, err = store.Query(history.Query{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"3", "1"}, ids(runs))
+
+	// a complete run missing only its newline is kept
+	require.NoError(t, os.WriteFile(path, []byte(`{"id":"1","task":"backup"}`), 0o644))
+	runs, err = store.Query(history.Query{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"1"}, ids(runs))
+	require.NoError(t, store.Record(history.Run{ID: "2", Task: "backup"}))
+	runs, err = store.Query(history.Query{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"2", "1"}, ids(runs))
+
+	// partial lines longer than the chunk read at a time
+	require.NoError(t, os.WriteFile(path, []byte(`{"id":"1","task":"backup"}`+"\n"+`{"id":"2","spec":"`+strings.Repeat("x", 10000)), 0o644))
+	require.NoError(t, store.Record(history.Run{ID: "3", Task: "backup"}))
+	runs, err = store.Query(history.Query{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"3", "1"}, ids(runs))
+
+	require.NoError(t, os.WriteFile(path, []byte("garbage\n{}\n"), 0o644))
+	_, err = store.Query(history.Query{})
+	require.ErrorContains(t, err, "history.jsonl:1")
+}
+
+func TestFileStoreConcurrentCompact(t *testing.T) {
+	now := time.Now().UTC()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	// separate stores on the same file, like separate processes
+	compacting := history.NewFileStore(path, history.WithRetainAge(time.Hour))
+	recording := history.NewFileStore(path)
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			require.NoError(t, compacting.Record(history.Run{ID: "old", Task: "backup", Start: now.Add(-2 * time.Hour)}))
+			_, err := compacting.Compact()
+			require.NoError(t, err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			require.NoError(t, recording.Record(history.Run{ID: strconv.Itoa(i), Task: "backup", Start: now}))
+		}
+	}()
+	wg.Wait()
+
+	runs, err := recording.Query(history.Query{Since: now})
+	require.NoError(t, err)
+	require.Len(t, runs, 200)
+}
@@ -0,0 +1,149 @@
+package history
+
+import (
+	"sort"
+	"time"
+
+	"github.com/dunv/utask"
+)
+
+// Outcome of a run
+type Status string
+
+const (
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	// The task was stopped via its context
+	StatusCanceled Status = "canceled"
+)
+
+// A finished run of a task
+type Run struct {
+	ID   string `json:"id"`
+	Task string `json:"task"`
+	// What was run, e.g. "command 'backup.sh --full'"
+	Spec     string            `json:"spec,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Start    time.Time         `json:"start"`
+	End      time.Time         `json:"end"`
+	Status   Status            `json:"status"`
+	ExitCode int               `json:"exitCode"`
+	Error    string            `json:"error,omitempty"`
+	// Where the output of the run was written to (e.g. the path of a file sink)
+	Log string `json:"log,omitempty"`
+}
+
+func (r Run) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// Filter for runs, zero values match everything
+type Query struct {
+	Task string
+	// All labels have to match
+	Labels map[string]string
+	Status Status
+	// Runs started at or after Since
+	Since time.Time
+	// Runs started before Until
+	Until time.Time
+	// Maximum number of runs returned
+	Limit int
+}
+
+func (q Query) matches(r Run) bool {
+	if q.Task != "" && r.Task != q.Task {
+		return false
+	}
+	if q.Status != "" && r.Status != q.Status {
+		return false
+	}
+	if !q.Since.IsZero() && r.Start.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !r.Start.Before(q.Until) {
+		return false
+	}
+	for key, value := range q.Labels {
+		if actual, ok := r.Labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// apply q to runs (in the order they were recorded), returns the matching runs
+// newest first (runs with the same start time: last recorded first)
+func (q Query) apply(runs []Run) []Run {
+	matching := []Run{}
+	for i := len(runs) - 1; i >= 0; i-- {
+		if q.matches(runs[i]) {
+			matching = append(matching, runs[i])
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		return matching[i].Start.After(matching[j].Start)
+	})
+	if q.Limit > 0 && len(matching) > q.Limit {
+		matching = matching[:q.Limit]
+	}
+	return matching
+}
+
+// Persists runs of tasks
+type Store interface {
+	// Add a finished run
+	Record(run Run) error
+	// Runs matching the query, newest first
+	Query(q Query) ([]Run, error)
+	// Remove runs according to the store's retention policy, returns the number of removed runs
+	Compact() (int, error)
+}
+
+// Most recent run of task with the given status (any status if empty), nil if there is none
+func Last(store Store, task string, status Status) (*Run, error) {
+	runs, err := store.Query(Query{Task: task, Status: status, Limit: 1})
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+// Lifecycle hook (see WithShellLifecycleHook, WithFunctionLifecycleHook) recording every run in store
+func Hook(store Store, opts ...HookOption) func(utask.LifecycleEvent) {
+	mergedOpts := hookOptions{}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+
+	return func(e utask.LifecycleEvent) {
+		if e.Phase == utask.LifecycleStart {
+			return
+		}
+
+		run := Run{
+			ID:       e.RunID,
+			Task:     e.Name,
+			Spec:     e.Description,
+			Labels:   mergedOpts.labels,
+			Start:    e.StartedAt,
+			End:      e.EndedAt,
+			Status:   StatusSucceeded,
+			ExitCode: e.ExitCode,
+		}
+		if mergedOpts.log != nil {
+			run.Log = mergedOpts.log(e)
+		}
+		if e.Err != nil {
+			run.Status = StatusFailed
+			if e.Canceled {
+				run.Status = StatusCanceled
+			}
+			run.Error = e.Err.Error()
+		}
+
+		if err := store.Record(run); err != nil && mergedOpts.errorHandler != nil {
+			mergedOpts.errorHandler(err)
+		}
+	}
+}
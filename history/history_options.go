@@ -0,0 +1,90 @@
+package history
+
+import (
+	"time"
+
+	"github.com/dunv/utask"
+)
+
+type hookOptions struct {
+	labels       map[string]string
+	log          func(utask.LifecycleEvent) string
+	errorHandler func(error)
+}
+
+// Options for a history hook
+type HookOption interface {
+	apply(*hookOptions)
+}
+
+type funcHookOption struct {
+	f func(*hookOptions)
+}
+
+// nolint:unused
+func (fho *funcHookOption) apply(o *hookOptions) {
+	fho.f(o)
+}
+
+func newFuncHookOption(f func(*hookOptions)) *funcHookOption {
+	return &funcHookOption{f: f}
+}
+
+// Labels attached to every recorded run (e.g. {"env": "prod"})
+func WithLabels(labels map[string]string) HookOption {
+	return newFuncHookOption(func(o *hookOptions) {
+		o.labels = labels
+	})
+}
+
+// Location of the run's output attached to every recorded run, called once the run ended
+// (e.g. the path of the run's file sink: func(utask.LifecycleEvent) string { return sink.Path() })
+func WithLog(location func(utask.LifecycleEvent) string) HookOption {
+	return newFuncHookOption(func(o *hookOptions) {
+		o.log = location
+	})
+}
+
+// Function called if a run could not be recorded (default: errors are dropped)
+func WithErrorHandler(handler func(error)) HookOption {
+	return newFuncHookOption(func(o *hookOptions) {
+		o.errorHandler = handler
+	})
+}
+
+type fileStoreOptions struct {
+	retainRuns int
+	retainAge  time.Duration
+}
+
+// Options for a file store
+type FileStoreOption interface {
+	apply(*fileStoreOptions)
+}
+
+type funcFileStoreOption struct {
+	f func(*fileStoreOptions)
+}
+
+// nolint:unused
+func (ffso *funcFileStoreOption) apply(o *fileStoreOptions) {
+	ffso.f(o)
+}
+
+func newFuncFileStoreOption(f func(*fileStoreOptions)) *funcFileStoreOption {
+	return &funcFileStoreOption{f: f}
+}
+
+// Keep at most n runs per task when compacting, newest are kept (default: 0, unlimited)
+func WithRetainRuns(n int) FileStoreOption {
+	return newFuncFileStoreOption(func(o *fileStoreOptions) {
+		o.retainRuns = n
+	})
+}
+
+// Remove runs started longer than d ago when compacting (default: 0, unlimited)
+func WithRetainAge(d time.Duration) FileStoreOption {
+	return newFuncFileStoreOption(func(o *fileStoreOptions) {
+		o.retainAge = d
+	})
+}
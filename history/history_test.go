@@ -0,0 +1,113 @@
+package history_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dunv/utask"
+	"github.com/dunv/utask/history"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHookRecordsRuns(t *testing.T) {
+	store := history.NewFileStore(filepath.Join(t.TempDir(), "history.jsonl"))
+	hook := history.Hook(store,
+		history.WithLabels(map[string]string{"env": "prod"}),
+		history.WithLog(func(e utask.LifecycleEvent) string { return "/var/log/backup/" + e.RunID + ".log" }),
+	)
+
+	for _, script := range []string{"exit 0", "exit 4"} {
+		task, err := utask.NewShellTask(
+			utask.WithShellName("backup"),
+			utask.WithShellCommand("sh", "-c", script),
+			utask.WithShellLifecycleHook(hook),
+		)
+		require.NoError(t, err)
+		_ = task.Run()
+	}
+
+	runs, err := store.Query(history.Query{Task: "backup"})
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+
+	failed := runs[0]
+	require.Equal(t, history.StatusFailed, failed.Status)
+	require.Equal(t, 4, failed.ExitCode)
+	require.Equal(t, "exit status 4", failed.Error)
+	require.Equal(t, "command 'sh -c exit 4'", failed.Spec)
+	require.Equal(t, map[string]string{"env": "prod"}, failed.Labels)
+	require.Equal(t, "/var/log/backup/"+failed.ID+".log", failed.Log)
+	require.NotEmpty(t, failed.ID)
+
+	last, err := history.Last(store, "backup", history.StatusSucceeded)
+	require.NoError(t, err)
+	require.Equal(t, 0, last.ExitCode)
+	require.Greater(t, last.Duration(), time.Duration(0))
+	require.False(t, last.Start.After(failed.Start))
+}
+
+func TestHookCanceled(t *testing.T) {
+	store := history.NewFileStore(filepath.Join(t.TempDir(), "history.jsonl"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	task, err := utask.NewFunctionTask(
+		utask.WithFunctionName("job"),
+		utask.WithFunctionContext(ctx),
+		utask.WithFunction(func(ctx context.Context, stdout, stderr io.Writer) error {
+			return ctx.Err()
+		}),
+		utask.WithFunctionLifecycleHook(history.Hook(store)),
+	)
+	require.NoError(t, err)
+	require.ErrorIs(t, task.Run(), context.Canceled)
+
+	last, err := history.Last(store, "job", "")
+	require.NoError(t, err)
+	require.Equal(t, history.StatusCanceled, last.Status)
+}
+
+func TestHookShellTimeout(t *testing.T) {
+	store := history.NewFileStore(filepath.Join(t.TempDir(), "history.jsonl"))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	task, err := utask.NewShellTask(
+		utask.WithShellName("sleep"),
+		utask.WithShellContext(ctx),
+		utask.WithShellCommand("sleep", "10"),
+		utask.WithShellLifecycleHook(history.Hook(store)),
+	)
+	require.NoError(t, err)
+	require.Error(t, task.Run())
+
+	last, err := history.Last(store, "sleep", "")
+	require.NoError(t, err)
+	require.Equal(t, history.StatusCanceled, last.Status)
+	require.Equal(t, "signal: terminated", last.Error)
+}
+
+type failingStore struct{}
+
+func (failingStore) Record(history.Run) error                   { return errors.New("disk full") }
+func (failingStore) Query(history.Query) ([]history.Run, error) { return nil, nil }
+func (failingStore) Compact() (int, error)                      { return 0, nil }
+
+func TestHookErrorHandler(t *testing.T) {
+	var recordErr error
+	task, err := utask.NewFunctionTask(
+		utask.WithFunction(func(ctx context.Context, stdout, stderr io.Writer) error { return nil }),
+		utask.WithFunctionLifecycleHook(history.Hook(failingStore{}, history.WithErrorHandler(func(err error) {
+			recordErr = err
+		}))),
+	)
+	require.NoError(t, err)
+	require.NoError(t, task.Run())
+	require.EqualError(t, recordErr, "disk full")
+
+	last, err := history.Last(failingStore{}, "function", "")
+	require.NoError(t, err)
+	require.Nil(t, last)
+}
@@ -0,0 +1,218 @@
+package utask
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"text/template"
+	"time"
+
+	"github.com/dunv/utask/output"
+)
+
+// Phase of a task's lifecycle
+type LifecyclePhase string
+
+const (
+	LifecycleStart   LifecyclePhase = "start"
+	LifecycleSuccess LifecyclePhase = "success"
+	LifecycleFailure LifecyclePhase = "failure"
+)
+
+// Data available to lifecycle templates and hooks
+type LifecycleEvent struct {
+	Phase LifecyclePhase
+	// Name of the task (see WithShellName, WithFunctionName)
+	Name string
+	// Unique identifier of this run of the task
+	RunID string
+	// Process id of a shell task (0 for function tasks and in the start banner)
+	Pid int
+	// Human readable description of what is being run, e.g. "command 'ls -la'"
+	Description string
+	StartedAt   time.Time
+	// Zero for LifecycleStart
+	EndedAt time.Time
+	// Zero for LifecycleStart
+	Duration time.Duration
+	// 0 on success, the process' exit code if available, -1 otherwise
+	ExitCode int
+	// Nil unless Phase is LifecycleFailure
+	Err error
+	// True if the task failed because its context was canceled or timed out
+	// (shell tasks then fail with the signal they were stopped with)
+	Canceled bool
+	// Attempt of the task if it is retried or restarted (see ContextWithAttempt), 0 if unknown
+	Attempt int
+}
+
+// Templates (text/template syntax) for the banners printed at the start and end
+// of a task. The data supplied is a LifecycleEvent, additionally the function
+// "duration" is available for rounding durations to a readable precision.
+// An empty template disables the respective banner.
+type LifecycleTemplate struct {
+	Start   string
+	Success string
+	Failure string
+}
+
+// Default banners
+//   - Running command '/bin/sh -c echo hello'
+//   - Done executing
+//   - Failed after 3.2s: exit status 1
+var DefaultLifecycleTemplate = LifecycleTemplate{
+	Start:   "Running {{.Description}}",
+	Success: "Done executing",
+	Failure: "Failed after {{duration .Duration}}: {{.Err}}",
+}
+
+var lifecycleFuncs = template.FuncMap{
+	"duration": output.FormatDuration,
+}
+
+func parseLifecycleTemplate(t LifecycleTemplate) (*template.Template, error) {
+	root := template.New("lifecycle").Funcs(lifecycleFuncs)
+	for phase, text := range map[LifecyclePhase]string{
+		LifecycleStart:   t.Start,
+		LifecycleSuccess: t.Success,
+		LifecycleFailure: t.Failure,
+	} {
+		if _, err := root.New(string(phase)).Parse(text); err != nil {
+			return nil, fmt.Errorf("utask: invalid %s lifecycle template: %w", phase, err)
+		}
+	}
+	return root, nil
+}
+
+// shared start/end handling for all task types
+//   - start: print the start banner
+//   - running: the task is running, call hooks
+//   - end: call hooks and print the end banner
+type lifecycle struct {
+	ctx         context.Context
+	name        string
+	description string
+	enabled     bool
+	tmpl        *template.Template
+	w           io.Writer
+	hooks       []func(LifecycleEvent)
+	group       *groupedOutput
+	runID       string
+	pid         int
+	startedAt   time.Time
+}
+
+func newLifecycle[T specificOptions](opts options[T], description string) (*lifecycle, error) {
+	tmpl := opts.lifecycleTemplate
+	if tmpl == nil {
+		var err error
+		if tmpl, err = parseLifecycleTemplate(DefaultLifecycleTemplate); err != nil {
+			return nil, err
+		}
+	}
+	w := opts.lifecycleOutput
+	if w == nil {
+		w = opts.stdout
+	}
+	if w == nil {
+		w = io.Discard
+	}
+	return &lifecycle{
+		ctx:         opts.ctx,
+		name:        opts.name,
+		description: description,
+		enabled:     opts.printStartAndEndInOutput,
+		tmpl:        tmpl,
+		w:           w,
+		hooks:       opts.lifecycleHooks,
+		group:       opts.groupedOutput,
+	}, nil
+}
+
+func (l *lifecycle) start() {
+	l.startedAt = time.Now()
+	l.runID = output.NewRunID()
+	l.pid = 0
+	if l.group != nil {
+		l.group.begin(l.name)
+	}
+	l.print(l.startEvent())
+}
+
+func (l *lifecycle) running(pid int) {
+	l.pid = pid
+	l.call(l.startEvent())
+}
+
+func (l *lifecycle) startEvent() LifecycleEvent {
+	return LifecycleEvent{
+		Phase:       LifecycleStart,
+		Name:        l.name,
+		RunID:       l.runID,
+		Pid:         l.pid,
+		Description: l.description,
+		StartedAt:   l.startedAt,
+		Attempt:     AttemptFromContext(l.ctx),
+	}
+}
+
+func (l *lifecycle) end(err error) {
+	endedAt := time.Now()
+	e := LifecycleEvent{
+		Phase:       LifecycleSuccess,
+		Name:        l.name,
+		RunID:       l.runID,
+		Pid:         l.pid,
+		Description: l.description,
+		StartedAt:   l.startedAt,
+		EndedAt:     endedAt,
+		Duration:    endedAt.Sub(l.startedAt),
+		ExitCode:    exitCode(err),
+		Err:         err,
+		Attempt:     AttemptFromContext(l.ctx),
+	}
+	if err != nil {
+		e.Phase = LifecycleFailure
+		e.Canceled = l.ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+	}
+	l.call(e)
+	l.print(e)
+	if l.group != nil {
+		l.group.end(err)
+	}
+}
+
+func (l *lifecycle) call(e LifecycleEvent) {
+	for _, hook := range l.hooks {
+		hook(e)
+	}
+}
+
+func (l *lifecycle) print(e LifecycleEvent) {
+	if !l.enabled {
+		return
+	}
+	buf := &bytes.Buffer{}
+	if err := l.tmpl.ExecuteTemplate(buf, string(e.Phase), e); err != nil {
+		fmt.Fprintf(buf, "utask: could not render %s banner: %s", e.Phase, err)
+	}
+	if buf.Len() == 0 {
+		return
+	}
+	buf.WriteByte('\n')
+	_, _ = l.w.Write(buf.Bytes())
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
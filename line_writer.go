@@ -0,0 +1,123 @@
+package utask
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// helper for writing whole lines to the underlying writer
+//   - complete lines are passed on, a trailing partial line is held back
+//     until it is completed or flushed
+//   - safe for concurrent use
+type lineWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf []byte
+}
+
+func newLineWriter(w io.Writer) *lineWriter {
+	return &lineWriter{w: w}
+}
+
+func (w *lineWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	i := bytes.LastIndexByte(w.buf, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+
+	_, err = w.w.Write(w.buf[:i+1])
+	w.buf = append(w.buf[:0], w.buf[i+1:]...)
+	return len(p), err
+}
+
+// Write out a pending partial line (terminated with a newline)
+// and flush the underlying writer
+func (w *lineWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		if _, err := w.w.Write(append(w.buf, '\n')); err != nil {
+			return err
+		}
+		w.buf = w.buf[:0]
+	}
+
+	flush(w.w)
+	return nil
+}
+
+// helper for writing to multiple writers (like io.MultiWriter),
+// Flush is passed on to all writers
+type teeWriter []io.Writer
+
+// returns add if w is nil, otherwise a writer writing to both
+func tee(w io.Writer, add io.Writer) io.Writer {
+	if w == nil {
+		return add
+	}
+	return teeWriter{w, add}
+}
+
+func (t teeWriter) Write(p []byte) (n int, err error) {
+	for _, w := range t {
+		if n, err = w.Write(p); err != nil {
+			return n, err
+		}
+	}
+	return len(p), nil
+}
+
+func (t teeWriter) Flush() error {
+	for _, w := range t {
+		flush(w)
+	}
+	return nil
+}
+
+// flush the writer if it holds back partial lines
+func flush(w io.Writer) {
+	if f, ok := w.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+}
+
+// helper for serializing writes to a writer
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.w.Write(p)
+}
+
+func (s *syncWriter) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flush(s.w)
+	return nil
+}
+
+// true if a and b are the same (comparable) writer
+func sameWriter(a io.Writer, b io.Writer) bool {
+	if !isComparable(a) || !isComparable(b) {
+		return false
+	}
+	return a == b
+}
+
+// true if w can be compared with == (and used as a map key) without panicking
+func isComparable(w io.Writer) bool {
+	return w != nil && reflect.TypeOf(w).Comparable()
+}
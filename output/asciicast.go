@@ -0,0 +1,332 @@
+package output
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Event types of the asciicast v2 format
+const (
+	CastOutput = "o"
+	CastInput  = "i"
+	CastMarker = "m"
+	CastResize = "r"
+)
+
+// prefix of the marker recording the exit code
+const castExitMarker = "exit "
+
+// Header (first line) of an asciicast v2 recording
+type CastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// A single event of an asciicast v2 recording
+type CastEvent struct {
+	// Time since the start of the recording
+	Time time.Duration
+	// One of CastOutput, CastInput, CastMarker, CastResize
+	Type string
+	Data string
+}
+
+func (e CastEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{e.Time.Seconds(), e.Type, e.Data})
+}
+
+func (e *CastEvent) UnmarshalJSON(b []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 3 {
+		return fmt.Errorf("output: invalid asciicast event %s", b)
+	}
+	var seconds float64
+	if err := json.Unmarshal(raw[0], &seconds); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw[1], &e.Type); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw[2], &e.Data); err != nil {
+		return err
+	}
+	e.Time = time.Duration(seconds * float64(time.Second))
+	return nil
+}
+
+// Records output as asciicast v2 (https://docs.asciinema.org/manual/asciicast/v2/),
+// which can be replayed with asciinema or ReadCast
+//   - stdout and stderr are recorded as output events (as seen on a terminal),
+//     stderr is colored to tell it apart (see WithCastStderrColor)
+//   - the exit code is recorded as a marker ("exit 1")
+//   - safe for concurrent use
+type castRecorder struct {
+	mu      sync.Mutex
+	opts    castOptions
+	w       io.Writer
+	start   time.Time
+	closed  bool
+	streams []*castStream
+}
+
+// Create a recorder writing an asciicast v2 recording to w, the header is written immediately
+func NewCastRecorder(w io.Writer, opts ...CastOption) (*castRecorder, error) {
+	mergedOpts := castOptions{
+		width:       80,
+		height:      24,
+		stderrColor: DefaultCastStderrColor,
+	}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+
+	start := time.Now()
+	header := CastHeader{
+		Version:   2,
+		Width:     mergedOpts.width,
+		Height:    mergedOpts.height,
+		Timestamp: start.Unix(),
+		Title:     mergedOpts.title,
+		Env: map[string]string{
+			"SHELL": os.Getenv("SHELL"),
+			"TERM":  os.Getenv("TERM"),
+		},
+	}
+	line, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(line, '\n')); err != nil {
+		return nil, err
+	}
+
+	return &castRecorder{opts: mergedOpts, w: w, start: start}, nil
+}
+
+// Writer recording output events
+func (c *castRecorder) Stdout() io.Writer {
+	return c.stream("")
+}
+
+// Writer recording output events, colored with the stderr color (see WithCastStderrColor)
+func (c *castRecorder) Stderr() io.Writer {
+	return c.stream(c.opts.stderrColor)
+}
+
+func (c *castRecorder) stream(color string) *castStream {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := &castStream{recorder: c, color: color}
+	c.streams = append(c.streams, s)
+	return s
+}
+
+// Record a marker
+func (c *castRecorder) Marker(label string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.writeEvent(CastMarker, label)
+}
+
+// Record the exit code of the task and end the recording.
+// Incomplete runes still pending are recorded before.
+func (c *castRecorder) Exit(code int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, s := range c.streams {
+		if err := s.flushLocked(); err != nil {
+			return err
+		}
+	}
+	if err := c.writeEvent(CastMarker, castExitMarker+strconv.Itoa(code)); err != nil {
+		return err
+	}
+	c.closed = true
+	return nil
+}
+
+func (c *castRecorder) writeEvent(typ string, data string) error {
+	if c.closed {
+		return ErrClosed
+	}
+
+	line, err := json.Marshal(CastEvent{Time: time.Since(c.start), Type: typ, Data: data})
+	if err != nil {
+		return err
+	}
+	_, err = c.w.Write(append(line, '\n'))
+	return err
+}
+
+type castStream struct {
+	recorder *castRecorder
+	color    string // SGR parameters the output is colored with ("" for none)
+	pending  []byte // start of a rune split across writes
+}
+
+func (s *castStream) Write(p []byte) (n int, err error) {
+	c := s.recorder
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, ErrClosed
+	}
+
+	// runes split across writes are recorded once they are complete
+	data := append(s.pending, p...)
+	end := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+	s.pending = append([]byte{}, data[end:]...)
+
+	if end == 0 {
+		return len(p), nil
+	}
+	if err := s.writeOutput(data[:end]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Record pending bytes of an incomplete rune (called when a task ends)
+func (s *castStream) Flush() error {
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+
+	return s.flushLocked()
+}
+
+func (s *castStream) flushLocked() error {
+	if len(s.pending) == 0 || s.recorder.closed {
+		return nil
+	}
+	// the rune stays incomplete, record it as a single replacement character
+	data := []byte(strings.ToValidUTF8(string(s.pending), string(utf8.RuneError)))
+	s.pending = nil
+	return s.writeOutput(data)
+}
+
+func (s *castStream) writeOutput(data []byte) error {
+	if s.color == "" {
+		return s.recorder.writeEvent(CastOutput, string(data))
+	}
+	return s.recorder.writeEvent(CastOutput, "\x1b["+s.color+"m"+string(data)+"\x1b[39m")
+}
+
+// A parsed asciicast v2 recording
+type Cast struct {
+	Header CastHeader
+	Events []CastEvent
+}
+
+// Parse an asciicast v2 recording
+func ReadCast(r io.Reader) (*Cast, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("output: empty asciicast")
+	}
+
+	cast := &Cast{}
+	if err := json.Unmarshal(sc.Bytes(), &cast.Header); err != nil {
+		return nil, fmt.Errorf("output: invalid asciicast header: %w", err)
+	}
+	if cast.Header.Version != 2 {
+		return nil, fmt.Errorf("output: unsupported asciicast version %d", cast.Header.Version)
+	}
+
+	for sc.Scan() {
+		if len(strings.TrimSpace(sc.Text())) == 0 {
+			continue
+		}
+		e := CastEvent{}
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, err
+		}
+		cast.Events = append(cast.Events, e)
+	}
+	return cast, sc.Err()
+}
+
+// Exit code recorded by castRecorder.Exit, false if none was recorded
+func (c *Cast) ExitCode() (int, bool) {
+	for i := len(c.Events) - 1; i >= 0; i-- {
+		e := c.Events[i]
+		if e.Type == CastMarker && strings.HasPrefix(e.Data, castExitMarker) {
+			if code, err := strconv.Atoi(strings.TrimPrefix(e.Data, castExitMarker)); err == nil {
+				return code, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Duration of the recording (time of the last event)
+func (c *Cast) Duration() time.Duration {
+	if len(c.Events) == 0 {
+		return 0
+	}
+	return c.Events[len(c.Events)-1].Time
+}
+
+// Write output events to w with their original timing divided by speed
+// (2 replays twice as fast). A speed <= 0 replays without any delay.
+func (c *Cast) Replay(ctx context.Context, w io.Writer, speed float64) error {
+	start := time.Now()
+	for _, e := range c.Events {
+		if e.Type != CastOutput {
+			continue
+		}
+
+		if speed > 0 {
+			due := time.Duration(float64(e.Time) / speed)
+			if wait := due - time.Since(start); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				}
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(w, e.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package output
+
+// Color (SGR parameters) stderr is recorded with by default (red)
+const DefaultCastStderrColor = "31"
+
+type castOptions struct {
+	width       int
+	height      int
+	title       string
+	stderrColor string
+}
+
+// Options for a cast recorder
+type CastOption interface {
+	apply(*castOptions)
+}
+
+type funcCastOption struct {
+	f func(*castOptions)
+}
+
+// nolint:unused
+func (fco *funcCastOption) apply(o *castOptions) {
+	fco.f(o)
+}
+
+func newFuncCastOption(f func(*castOptions)) *funcCastOption {
+	return &funcCastOption{f: f}
+}
+
+// Terminal size recorded in the header (default: 80x24)
+func WithCastSize(width int, height int) CastOption {
+	return newFuncCastOption(func(o *castOptions) {
+		o.width = width
+		o.height = height
+	})
+}
+
+// Title recorded in the header
+func WithCastTitle(title string) CastOption {
+	return newFuncCastOption(func(o *castOptions) {
+		o.title = title
+	})
+}
+
+// Color (SGR parameters, e.g. "1;33" for bold yellow) stderr output is recorded with,
+// an empty string records stderr unchanged (default: DefaultCastStderrColor)
+func WithCastStderrColor(sgr string) CastOption {
+	return newFuncCastOption(func(o *castOptions) {
+		o.stderrColor = sgr
+	})
+}
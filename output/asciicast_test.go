@@ -0,0 +1,100 @@
+package output_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dunv/utask/output"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCastRecordAndReplay(t *testing.T) {
+	buf := &bytes.Buffer{}
+	rec, err := output.NewCastRecorder(buf, output.WithCastSize(120, 40), output.WithCastTitle("backup"))
+	require.NoError(t, err)
+
+	stdout, stderr := rec.Stdout(), rec.Stderr()
+	_, _ = stdout.Write([]byte("hello \xe2\x9c"))
+	_, _ = stdout.Write([]byte("\x93\r\n"))
+	time.Sleep(100 * time.Millisecond)
+	_, _ = stderr.Write([]byte("failure\r\n"))
+	require.NoError(t, rec.Exit(1))
+	_, err = stdout.Write([]byte("after exit"))
+	require.ErrorIs(t, err, output.ErrClosed)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 5)
+	require.Regexp(t, `^\{"version":2,"width":120,"height":40,"timestamp":\d+,"title":"backup"`, lines[0])
+	// the split rune is recorded once it is complete
+	require.Regexp(t, `^\[[0-9.e-]+,"o","hello "\]$`, lines[1])
+	require.Regexp(t, `^\[[0-9.e-]+,"o","✓\\r\\n"\]$`, lines[2])
+	// stderr is colored
+	require.Regexp(t, `^\[0\.1\d*,"o","\\u001b\[31mfailure\\r\\n\\u001b\[39m"\]$`, lines[3])
+	require.Regexp(t, `^\[[0-9.]+,"m","exit 1"\]$`, lines[4])
+
+	cast, err := output.ReadCast(buf)
+	require.NoError(t, err)
+	require.Equal(t, 120, cast.Header.Width)
+	require.Equal(t, 40, cast.Header.Height)
+	require.Len(t, cast.Events, 4)
+	code, ok := cast.ExitCode()
+	require.True(t, ok)
+	require.Equal(t, 1, code)
+
+	// accelerated replay keeps relative timing
+	replayed := &bytes.Buffer{}
+	start := time.Now()
+	require.NoError(t, cast.Replay(context.Background(), replayed, 2))
+	require.GreaterOrEqual(t, time.Since(start), cast.Duration()/2-10*time.Millisecond)
+	require.Less(t, time.Since(start), cast.Duration())
+	require.Equal(t, "hello ✓\r\n\x1b[31mfailure\r\n\x1b[39m", replayed.String())
+}
+
+func TestCastPendingRuneOnExit(t *testing.T) {
+	buf := &bytes.Buffer{}
+	rec, err := output.NewCastRecorder(buf, output.WithCastStderrColor(""))
+	require.NoError(t, err)
+	_, _ = rec.Stderr().Write([]byte("cut \xe2\x9c"))
+	require.NoError(t, rec.Exit(0))
+
+	cast, err := output.ReadCast(buf)
+	require.NoError(t, err)
+	require.Len(t, cast.Events, 3)
+	require.Equal(t, "cut ", cast.Events[0].Data)
+	// the incomplete rune is not lost
+	require.Equal(t, "\ufffd", cast.Events[1].Data)
+	require.Equal(t, output.CastMarker, cast.Events[2].Type)
+}
+
+func TestCastReplayCancel(t *testing.T) {
+	cast := &output.Cast{
+		Header: output.CastHeader{Version: 2, Width: 80, Height: 24},
+		Events: []output.CastEvent{
+			{Time: 0, Type: output.CastOutput, Data: "first\n"},
+			{Time: time.Hour, Type: output.CastOutput, Data: "second\n"},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	replayed := &bytes.Buffer{}
+	require.ErrorIs(t, cast.Replay(ctx, replayed, 1), context.DeadlineExceeded)
+	require.Equal(t, "first\n", replayed.String())
+
+	// without delays
+	replayed.Reset()
+	require.NoError(t, cast.Replay(context.Background(), replayed, 0))
+	require.Equal(t, "first\nsecond\n", replayed.String())
+}
+
+func TestReadCastInvalid(t *testing.T) {
+	_, err := output.ReadCast(strings.NewReader(""))
+	require.Error(t, err)
+	_, err = output.ReadCast(strings.NewReader(`{"version":1}`))
+	require.ErrorContains(t, err, "unsupported asciicast version 1")
+	_, err = output.ReadCast(strings.NewReader("{\"version\":2}\n[1,\"o\"]\n"))
+	require.ErrorContains(t, err, "invalid asciicast event")
+}
@@ -1,47 +1,165 @@
 package output
 
 import (
-	"bufio"
-	"bytes"
-	"strings"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// Returned when writing to a closed writer
+var ErrClosed = errors.New("output: writer closed")
+
+// Policy applied when the consumer of the channel cannot keep up
+type BackpressurePolicy int
+
+const (
+	// Block the writer until the line can be sent (default)
+	Block BackpressurePolicy = iota
+	// Discard the oldest line waiting in the channel to make room for the new one
+	// (needs the receiving side of the channel, see WithDropOldest; behaves like
+	// DropNewest for unbuffered channels or without it)
+	DropOldest
+	// Discard the line being written if the channel is full
+	DropNewest
 )
 
 // Buffered writer for output
 //   - collects all bytes
 //   - if a line is terminated with \n, publish the line (without \n) to the outputChannel
+//   - safe for concurrent use
 type channelWriter struct {
-	outChan chan<- string
-	w       *bytes.Buffer
-	r       *bufio.Reader
+	mu        sync.Mutex
+	opts      channelWriterOptions
+	outChan   chan<- string
+	drain     <-chan string // receiving side of outChan for DropOldest (nil if unknown)
+	splitter  *lineSplitter
+	dropped   atomic.Uint64
+	closed    bool
+	closing   chan struct{} // closed by Close to release a blocked send
+	closeOnce sync.Once
 }
 
-func (s channelWriter) Write(p []byte) (n int, err error) {
-	if n, err := s.w.Write(p); err != nil {
-		return n, err
+// Creates a buffered writer for collecting output
+//   - collects all bytes
+//   - if a line is terminated with \n, publish the line (without \n) to outChan
+//   - by default lines are trimmed and empty lines are dropped (see WithPreserveWhitespace,
+//     WithKeepEmptyLines, WithCarriageReturn and WithStripANSI)
+//   - lines longer than the maximum line length (default: DefaultMaxLineLength) are split
+//   - what happens if outChan is full is determined by the backpressure policy (default: Block)
+func NewChannelWriter(outChan chan<- string, opts ...ChannelWriterOption) *channelWriter {
+	mergedOpts := channelWriterOptions{
+		maxLineLength: DefaultMaxLineLength,
+		backpressure:  Block,
+	}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
 	}
 
-	for {
-		line, err := s.r.ReadString('\n')
-		if err != nil {
-			// put it back in the buffer if there is no \n
-			s.w.Write([]byte(line))
-			return len(p), nil
-		}
+	w := &channelWriter{
+		opts:    mergedOpts,
+		outChan: outChan,
+		closing: make(chan struct{}),
+	}
+	// only drain the channel the writer actually publishes to
+	if mergedOpts.drain != nil && (chan<- string)(mergedOpts.drain) == outChan {
+		w.drain = mergedOpts.drain
+	}
+	w.splitter = newLineSplitter(mergedOpts.maxLineLength, mergedOpts.lines.carriageReturn == CRAsTerminator, w.publish)
+	return w
+}
 
-		if len(line) > 1 { // '\n' is included in line
-			s.outChan <- strings.TrimSpace(string(line))
-		}
+func (w *channelWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, ErrClosed
 	}
+
+	w.splitter.write(p)
+	return len(p), nil
 }
 
-// Creates a buffered writer for collecting output
-//   - collects all bytes
-//   - if a line is terminated with \n, publish the line (without \n) to outChan
-func NewChannelWriter(outChan chan<- string) channelWriter {
-	w := bytes.NewBuffer([]byte{})
-	return channelWriter{
-		outChan: outChan,
-		w:       w,
-		r:       bufio.NewReader(w),
+// Publish a trailing partial line (if any)
+func (w *channelWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+
+	w.splitter.flush()
+	return nil
+}
+
+// Publish a trailing partial line (if any) and close the channel.
+// A Write blocked on a full channel (Block policy) is released, lines it could not
+// send are discarded and counted in Dropped.
+func (w *channelWriter) Close() error {
+	if !w.mu.TryLock() {
+		w.closeOnce.Do(func() { close(w.closing) })
+		w.mu.Lock()
+	}
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+
+	w.splitter.flush()
+	w.closed = true
+	close(w.outChan)
+	return nil
+}
+
+// Number of lines discarded because of the backpressure policy
+func (w *channelWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+func (w *channelWriter) publish(line []byte) {
+	if processed, ok := w.opts.lines.process(line); ok {
+		w.send(processed)
+	}
+}
+
+func (w *channelWriter) send(line string) {
+	switch w.opts.backpressure {
+	case DropNewest:
+		select {
+		case w.outChan <- line:
+		default:
+			w.dropped.Add(1)
+		}
+	case DropOldest:
+		for {
+			select {
+			case w.outChan <- line:
+				return
+			default:
+			}
+			if w.drain == nil || cap(w.outChan) == 0 {
+				w.dropped.Add(1)
+				return
+			}
+			select {
+			case <-w.drain:
+				w.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		// prefer sending over giving up if Close was called concurrently
+		select {
+		case w.outChan <- line:
+			return
+		default:
+		}
+		select {
+		case w.outChan <- line:
+		case <-w.closing:
+			w.dropped.Add(1)
+		}
 	}
 }
@@ -0,0 +1,78 @@
+package output
+
+type channelWriterOptions struct {
+	maxLineLength int
+	backpressure  BackpressurePolicy
+	drain         chan string
+	lines         lineOptions
+}
+
+// Options for a channel writer
+type ChannelWriterOption interface {
+	apply(*channelWriterOptions)
+}
+
+type funcChannelWriterOption struct {
+	f func(*channelWriterOptions)
+}
+
+// nolint:unused
+func (fco *funcChannelWriterOption) apply(o *channelWriterOptions) {
+	fco.f(o)
+}
+
+func newFuncChannelWriterOption(f func(*channelWriterOptions)) *funcChannelWriterOption {
+	return &funcChannelWriterOption{f: f}
+}
+
+// Maximum length of a line in bytes, longer lines are split (default: DefaultMaxLineLength)
+func WithMaxLineLength(n int) ChannelWriterOption {
+	return newFuncChannelWriterOption(func(o *channelWriterOptions) {
+		o.maxLineLength = n
+	})
+}
+
+// What to do if the channel is full (default: Block)
+func WithBackpressure(policy BackpressurePolicy) ChannelWriterOption {
+	return newFuncChannelWriterOption(func(o *channelWriterOptions) {
+		o.backpressure = policy
+	})
+}
+
+// Discard the oldest line waiting in the channel if it is full (see DropOldest).
+// ch must be the channel passed to NewChannelWriter, the writer receives from it to make room.
+func WithDropOldest(ch chan string) ChannelWriterOption {
+	return newFuncChannelWriterOption(func(o *channelWriterOptions) {
+		o.backpressure = DropOldest
+		o.drain = ch
+	})
+}
+
+// Publish lines as they are instead of trimming leading and trailing whitespace.
+// Useful for indented output like YAML or tracebacks.
+func WithPreserveWhitespace() ChannelWriterOption {
+	return newFuncChannelWriterOption(func(o *channelWriterOptions) {
+		o.lines.preserveWhitespace = true
+	})
+}
+
+// Publish empty lines instead of dropping them
+func WithKeepEmptyLines() ChannelWriterOption {
+	return newFuncChannelWriterOption(func(o *channelWriterOptions) {
+		o.lines.keepEmptyLines = true
+	})
+}
+
+// How carriage returns within a line are treated (default: CRKeep)
+func WithCarriageReturn(mode CarriageReturnMode) ChannelWriterOption {
+	return newFuncChannelWriterOption(func(o *channelWriterOptions) {
+		o.lines.carriageReturn = mode
+	})
+}
+
+// Remove ANSI escape sequences (colors, cursor movement) from lines
+func WithStripANSI() ChannelWriterOption {
+	return newFuncChannelWriterOption(func(o *channelWriterOptions) {
+		o.lines.stripANSI = true
+	})
+}
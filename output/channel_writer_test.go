@@ -0,0 +1,229 @@
+package output_test
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dunv/utask/output"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelWriterLines(t *testing.T) {
+	outChan := make(chan string, 10)
+	w := output.NewChannelWriter(outChan)
+	_, err := w.Write([]byte("foo"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("bar\nline2\n\npartial"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Equal(t, []string{"foobar", "line2", "partial"}, collect(outChan))
+}
+
+func TestChannelWriterMaxLineLength(t *testing.T) {
+	outChan := make(chan string, 10)
+	w := output.NewChannelWriter(outChan, output.WithMaxLineLength(4))
+	_, err := w.Write([]byte("0123"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("456789\nab\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Equal(t, []string{"0123", "4567", "89", "ab"}, collect(outChan))
+}
+
+func TestChannelWriterDropNewest(t *testing.T) {
+	outChan := make(chan string, 2)
+	w := output.NewChannelWriter(outChan, output.WithBackpressure(output.DropNewest))
+	_, err := w.Write([]byte("1\n2\n3\n4\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Equal(t, []string{"1", "2"}, collect(outChan))
+	require.Equal(t, uint64(2), w.Dropped())
+}
+
+func TestChannelWriterDropOldest(t *testing.T) {
+	outChan := make(chan string, 2)
+	w := output.NewChannelWriter(outChan, output.WithDropOldest(outChan))
+	_, err := w.Write([]byte("1\n2\n3\n4\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Equal(t, []string{"3", "4"}, collect(outChan))
+	require.Equal(t, uint64(2), w.Dropped())
+}
+
+func TestChannelWriterSendOnlyChannel(t *testing.T) {
+	outChan := make(chan string, 2)
+	var sendOnly chan<- string = outChan
+	w := output.NewChannelWriter(sendOnly, output.WithBackpressure(output.DropOldest))
+	_, err := w.Write([]byte("1\n2\n3\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	// the writer cannot receive from a send-only channel: DropOldest behaves like DropNewest
+	require.Equal(t, []string{"1", "2"}, collect(outChan))
+	require.Equal(t, uint64(1), w.Dropped())
+}
+
+func TestChannelWriterCloseReleasesBlockedWrite(t *testing.T) {
+	outChan := make(chan string)
+	w := output.NewChannelWriter(outChan)
+	written := make(chan error)
+	go func() {
+		_, err := w.Write([]byte("nobody reads this\n"))
+		written <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond) // let the write block
+	closed := make(chan error)
+	go func() { closed <- w.Close() }()
+	require.NoError(t, <-written)
+	require.NoError(t, <-closed)
+	require.Equal(t, []string{}, collect(outChan))
+	require.Equal(t, uint64(1), w.Dropped())
+}
+
+func TestChannelWriterFlushAndClose(t *testing.T) {
+	outChan := make(chan string, 10)
+	w := output.NewChannelWriter(outChan)
+	_, err := w.Write([]byte("partial"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	require.Equal(t, "partial", <-outChan)
+	require.NoError(t, w.Close())
+	_, err = w.Write([]byte("after close\n"))
+	require.ErrorIs(t, err, output.ErrClosed)
+	require.ErrorIs(t, w.Close(), output.ErrClosed)
+}
+
+func TestChannelWriterConcurrent(t *testing.T) {
+	outChan := make(chan string)
+	w := output.NewChannelWriter(outChan)
+	lines := []string{}
+	done := make(chan struct{})
+	go func() {
+		lines = collect(outChan)
+		close(done)
+	}()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _ = w.Write([]byte("a line\n"))
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, w.Close())
+	<-done
+	require.Len(t, lines, 1000)
+}
+
+func TestChannelWriterLineSemantics(t *testing.T) {
+	input := "  indented\r\n\n\x1b[31mred\x1b[0m\n10%\r50%\r100%\n"
+	tests := []struct {
+		name     string
+		opts     []output.ChannelWriterOption
+		expected []string
+	}{
+		{
+			name:     "default",
+			expected: []string{"indented", "\x1b[31mred\x1b[0m", "10%\r50%\r100%"},
+		},
+		{
+			name:     "preserveWhitespace",
+			opts:     []output.ChannelWriterOption{output.WithPreserveWhitespace()},
+			expected: []string{"  indented", "\x1b[31mred\x1b[0m", "10%\r50%\r100%"},
+		},
+		{
+			name:     "keepEmptyLines",
+			opts:     []output.ChannelWriterOption{output.WithKeepEmptyLines()},
+			expected: []string{"indented", "", "\x1b[31mred\x1b[0m", "10%\r50%\r100%"},
+		},
+		{
+			name:     "crAsTerminator",
+			opts:     []output.ChannelWriterOption{output.WithCarriageReturn(output.CRAsTerminator), output.WithKeepEmptyLines()},
+			expected: []string{"indented", "", "\x1b[31mred\x1b[0m", "10%", "50%", "100%"},
+		},
+		{
+			name:     "crCollapse",
+			opts:     []output.ChannelWriterOption{output.WithCarriageReturn(output.CRCollapse)},
+			expected: []string{"indented", "\x1b[31mred\x1b[0m", "100%"},
+		},
+		{
+			name:     "stripANSI",
+			opts:     []output.ChannelWriterOption{output.WithStripANSI()},
+			expected: []string{"indented", "red", "10%\r50%\r100%"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			outChan := make(chan string, 10)
+			w := output.NewChannelWriter(outChan, tc.opts...)
+			// write byte by byte to make sure terminators spanning writes are handled
+			for i := range input {
+				_, err := w.Write([]byte{input[i]})
+				require.NoError(t, err)
+			}
+			require.NoError(t, w.Close())
+			require.Equal(t, tc.expected, collect(outChan))
+		})
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	for input, expected := range map[string]string{
+		"\x1b[1;31mred\x1b[0m":       "red",
+		"\x1b]0;title\x07text":       "text",
+		"\x1b(B\x1b[mplain":          "plain", // tput sgr0
+		"\x1b)0\x1b(0lqk\x1b(B done": "lqk done",
+	} {
+		require.Equal(t, expected, output.StripANSI(input), "%q", input)
+	}
+}
+
+func BenchmarkChannelWriter(b *testing.B) {
+	chunk := bytes.Repeat([]byte("some line of output from a chatty process\n"), 100)
+	benchmarkChannelWriter(b, chunk, len(chunk)/3)
+}
+
+func BenchmarkChannelWriterSmallWrites(b *testing.B) {
+	chunk := []byte(strings.Repeat("x", 4000) + "\n")
+	benchmarkChannelWriter(b, chunk, 16)
+}
+
+func benchmarkChannelWriter(b *testing.B, chunk []byte, writeSize int) {
+	outChan := make(chan string, 1024)
+	done := make(chan struct{})
+	go func() {
+		for range outChan {
+		}
+		close(done)
+	}()
+
+	w := output.NewChannelWriter(outChan)
+	b.SetBytes(int64(len(chunk)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for start := 0; start < len(chunk); start += writeSize {
+			_, _ = w.Write(chunk[start:min(start+writeSize, len(chunk))])
+		}
+	}
+	b.StopTimer()
+	_ = w.Close()
+	<-done
+}
+
+// test-helper for reading all lines until the channel is closed
+func collect(outChan chan string) []string {
+	lines := []string{}
+	for line := range outChan {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,15 @@
+package output
+
+import "time"
+
+// Round durations so they are readable in output (e.g. 3.2s, 15ms)
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(100 * time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(time.Millisecond).String()
+	default:
+		return d.String()
+	}
+}
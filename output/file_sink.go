@@ -0,0 +1,419 @@
+package output
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Name of the index of runs within a task's log directory
+const IndexFileName = "index.jsonl"
+
+// lock file serializing index updates and retention of a task's log directory
+const indexLockName = "index.lock"
+
+// Information about a single run, as recorded in the index
+type RunInfo struct {
+	RunID string    `json:"runId"`
+	Task  string    `json:"task"`
+	Start time.Time `json:"start"`
+	// Zero if the run has not been closed (still running or crashed)
+	End time.Time `json:"end,omitempty"`
+	// Log files of the run in the order they were written (relative to the task's log directory),
+	// rotated segments are gzipped
+	Segments []string `json:"segments"`
+}
+
+// true if the sink of this run was closed properly
+func (r RunInfo) Complete() bool {
+	return !r.End.IsZero()
+}
+
+// Persists the output of a task run to <dir>/<task>/<run-id>.log
+//   - the log is rotated once it exceeds the maximum size, rotated segments are gzipped
+//     (<run-id>.<n>.log.gz)
+//   - runs are recorded in <dir>/<task>/index.jsonl (see ReadIndex)
+//   - old runs are removed according to the retention options when the sink is created,
+//     runs which are still being written (by any process) are never removed
+//   - Flush (called when a task ends) syncs the log file to disk
+//   - Close syncs everything to disk and must be called when the task ends
+//   - safe for concurrent use, also by multiple sinks or processes sharing dir
+type fileSink struct {
+	mu       sync.Mutex
+	opts     fileSinkOptions
+	dir      string
+	info     RunInfo
+	file     *os.File
+	size     int64
+	segments int
+	closed   bool
+}
+
+// Create a file sink for a new run of task
+func NewFileSink(dir string, task string, opts ...FileSinkOption) (*fileSink, error) {
+	mergedOpts := fileSinkOptions{}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+
+	if mergedOpts.runID == "" {
+		mergedOpts.runID = NewRunID()
+	}
+	if err := validName(task); err != nil {
+		return nil, fmt.Errorf("output: invalid task name: %w", err)
+	}
+	if err := validName(mergedOpts.runID); err != nil {
+		return nil, fmt.Errorf("output: invalid run id: %w", err)
+	}
+
+	taskDir := filepath.Join(dir, task)
+	if err := os.MkdirAll(taskDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &fileSink{
+		opts: mergedOpts,
+		dir:  taskDir,
+		info: RunInfo{
+			RunID:    mergedOpts.runID,
+			Task:     task,
+			Start:    time.Now(),
+			Segments: []string{mergedOpts.runID + ".log"},
+		},
+	}
+
+	unlock, err := lockTaskDir(taskDir)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	if err := appendIndex(taskDir, s.info); err != nil {
+		s.file.Close()
+		return nil, err
+	}
+
+	// the new run is locked, so it is never removed by retention
+	if err := applyRetention(taskDir, mergedOpts.retainRuns, mergedOpts.retainAge); err != nil {
+		s.file.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// a task name or run id must be usable as a single path component
+func validName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+		return fmt.Errorf("%q is not a valid file name", name)
+	}
+	return nil
+}
+
+// Identifier for a run, sortable by creation time (e.g. 20240101T120000.000Z-1a2b3c)
+func NewRunID() string {
+	random := make([]byte, 3)
+	_, _ = rand.Read(random)
+	return fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102T150405.000Z"), hex.EncodeToString(random))
+}
+
+// Identifier of the run this sink writes to
+func (s *fileSink) RunID() string {
+	return s.info.RunID
+}
+
+// Path of the log file currently written to
+func (s *fileSink) Path() string {
+	return filepath.Join(s.dir, s.info.RunID+".log")
+}
+
+func (s *fileSink) Write(p []byte) (n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, ErrClosed
+	}
+
+	if s.opts.maxSize > 0 && s.size > 0 && s.size+int64(len(p)) > s.opts.maxSize {
+		if err := s.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	// unbuffered, so output is not lost if the process crashes
+	n, err = s.file.Write(p)
+	s.size += int64(n)
+	return n, err
+}
+
+// Sync the log file to disk, called by tasks when they end
+func (s *fileSink) Flush() error {
+	return s.Sync()
+}
+
+// Sync the log file to disk
+func (s *fileSink) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	return s.file.Sync()
+}
+
+// Sync the log file to disk, close it and mark the run as complete in the index
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	s.closed = true
+
+	// closing the log file releases its lock, retention must not see the run
+	// unlocked before it is marked as complete
+	unlock, err := lockTaskDir(s.dir)
+	if err != nil {
+		s.file.Close()
+		return err
+	}
+	defer unlock()
+
+	if err := errors.Join(s.file.Sync(), s.file.Close()); err != nil {
+		return err
+	}
+
+	s.info.End = time.Now()
+	return appendIndex(s.dir, s.info)
+}
+
+// open the current log file and lock it, marking the run as active for retention
+func (s *fileSink) open() error {
+	f, err := os.OpenFile(s.Path(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		return fmt.Errorf("output: %s is in use: %w", s.Path(), err)
+	}
+	s.file = f
+	s.size = 0
+	return nil
+}
+
+// close the current log file, compress it into a numbered segment and start a new log file
+func (s *fileSink) rotate() error {
+	unlock, err := lockTaskDir(s.dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := errors.Join(s.file.Sync(), s.file.Close()); err != nil {
+		return err
+	}
+
+	s.segments++
+	segment := fmt.Sprintf("%s.%d.log.gz", s.info.RunID, s.segments)
+	if err := gzipFile(s.Path(), filepath.Join(s.dir, segment)); err != nil {
+		return err
+	}
+	if err := os.Remove(s.Path()); err != nil {
+		return err
+	}
+
+	// the current log file always is the last segment
+	s.info.Segments = append(s.info.Segments[:len(s.info.Segments)-1], segment, s.info.RunID+".log")
+	if err := appendIndex(s.dir, s.info); err != nil {
+		return err
+	}
+
+	return s.open()
+}
+
+// compress src into dst, dst only appears once it is complete
+func gzipFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(out)
+	_, copyErr := io.Copy(zw, in)
+	if err := errors.Join(copyErr, zw.Close(), out.Sync(), out.Close()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
+
+// exclusively lock a task's log directory (across processes), the returned function unlocks it
+func lockTaskDir(taskDir string) (func(), error) {
+	f, err := os.OpenFile(filepath.Join(taskDir, indexLockName), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	// closing the file releases the lock
+	return func() { f.Close() }, nil
+}
+
+// true if the log file of an incomplete run is still locked by its sink
+func isActive(taskDir string, run RunInfo) bool {
+	f, err := os.Open(filepath.Join(taskDir, run.RunID+".log"))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB) == syscall.EWOULDBLOCK
+}
+
+// Read the index of all runs of task (oldest first).
+// Runs which were not closed (still running or crashed) have a zero End.
+func ReadIndex(dir string, task string) ([]RunInfo, error) {
+	if err := validName(task); err != nil {
+		return nil, fmt.Errorf("output: invalid task name: %w", err)
+	}
+	return readIndex(filepath.Join(dir, task))
+}
+
+func readIndex(taskDir string) ([]RunInfo, error) {
+	f, err := os.Open(filepath.Join(taskDir, IndexFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return []RunInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// later entries of a run supersede earlier ones
+	runs := map[string]RunInfo{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		info := RunInfo{}
+		if err := json.Unmarshal(sc.Bytes(), &info); err != nil {
+			// ignore a partially written last line (crash while appending)
+			continue
+		}
+		runs[info.RunID] = info
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	sorted := make([]RunInfo, 0, len(runs))
+	for _, info := range runs {
+		sorted = append(sorted, info)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].Start.Equal(sorted[j].Start) {
+			return sorted[i].Start.Before(sorted[j].Start)
+		}
+		return sorted[i].RunID < sorted[j].RunID
+	})
+	return sorted, nil
+}
+
+// append an entry to the index, must be called with the task directory locked
+func appendIndex(taskDir string, info RunInfo) error {
+	line, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(taskDir, IndexFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	_, writeErr := f.Write(append(line, '\n'))
+	return errors.Join(writeErr, f.Sync(), f.Close())
+}
+
+// remove runs exceeding retainRuns (newest are kept) or older than retainAge
+// and rewrite the index accordingly, runs which are still active are kept.
+// Must be called with the task directory locked.
+func applyRetention(taskDir string, retainRuns int, retainAge time.Duration) error {
+	if retainRuns <= 0 && retainAge <= 0 {
+		return nil
+	}
+
+	runs, err := readIndex(taskDir)
+	if err != nil {
+		return err
+	}
+
+	kept := []RunInfo{}
+	removed := []RunInfo{}
+	for i, run := range runs {
+		tooMany := retainRuns > 0 && len(runs)-i > retainRuns
+		tooOld := retainAge > 0 && time.Since(run.Start) > retainAge
+		if (tooMany || tooOld) && (run.Complete() || !isActive(taskDir, run)) {
+			removed = append(removed, run)
+		} else {
+			kept = append(kept, run)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	for _, run := range removed {
+		for _, segment := range run.Segments {
+			if err := os.Remove(filepath.Join(taskDir, segment)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+
+	// rewrite the index atomically
+	tmp := filepath.Join(taskDir, IndexFileName+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	var encErr error
+	for _, run := range kept {
+		if encErr = enc.Encode(run); encErr != nil {
+			break
+		}
+	}
+	if err := errors.Join(encErr, f.Sync(), f.Close()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(taskDir, IndexFileName))
+}
@@ -0,0 +1,56 @@
+package output
+
+import "time"
+
+type fileSinkOptions struct {
+	runID      string
+	maxSize    int64
+	retainRuns int
+	retainAge  time.Duration
+}
+
+// Options for a file sink
+type FileSinkOption interface {
+	apply(*fileSinkOptions)
+}
+
+type funcFileSinkOption struct {
+	f func(*fileSinkOptions)
+}
+
+// nolint:unused
+func (ffo *funcFileSinkOption) apply(o *fileSinkOptions) {
+	ffo.f(o)
+}
+
+func newFuncFileSinkOption(f func(*fileSinkOptions)) *funcFileSinkOption {
+	return &funcFileSinkOption{f: f}
+}
+
+// Identifier of the run (default: NewRunID())
+func WithRunID(runID string) FileSinkOption {
+	return newFuncFileSinkOption(func(o *fileSinkOptions) {
+		o.runID = runID
+	})
+}
+
+// Rotate the log file once it exceeds maxSize bytes (default: 0, no rotation)
+func WithMaxSize(maxSize int64) FileSinkOption {
+	return newFuncFileSinkOption(func(o *fileSinkOptions) {
+		o.maxSize = maxSize
+	})
+}
+
+// Only keep the logs of the newest n runs of the task (default: 0, keep all)
+func WithRetainRuns(n int) FileSinkOption {
+	return newFuncFileSinkOption(func(o *fileSinkOptions) {
+		o.retainRuns = n
+	})
+}
+
+// Remove logs of runs started longer than age ago (default: 0, keep all)
+func WithRetainAge(age time.Duration) FileSinkOption {
+	return newFuncFileSinkOption(func(o *fileSinkOptions) {
+		o.retainAge = age
+	})
+}
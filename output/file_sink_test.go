@@ -0,0 +1,191 @@
+package output_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dunv/utask/output"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSink(t *testing.T) {
+	dir := t.TempDir()
+	s, err := output.NewFileSink(dir, "backup")
+	require.NoError(t, err)
+	_, err = s.Write([]byte("hello\n"))
+	require.NoError(t, err)
+
+	// run is indexed before it is closed
+	runs, err := output.ReadIndex(dir, "backup")
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.Equal(t, s.RunID(), runs[0].RunID)
+	require.False(t, runs[0].Complete())
+
+	require.NoError(t, s.Close())
+	_, err = s.Write([]byte("after close\n"))
+	require.ErrorIs(t, err, output.ErrClosed)
+
+	runs, err = output.ReadIndex(dir, "backup")
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.True(t, runs[0].Complete())
+	require.Equal(t, []string{s.RunID() + ".log"}, runs[0].Segments)
+
+	content, err := os.ReadFile(filepath.Join(dir, "backup", s.RunID()+".log"))
+	require.NoError(t, err)
+	require.Equal(t, "hello\n", string(content))
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	dir := t.TempDir()
+	s, err := output.NewFileSink(dir, "backup", output.WithRunID("run1"), output.WithMaxSize(20))
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, err := fmt.Fprintf(s, "line %d\n", i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.Close())
+
+	runs, err := output.ReadIndex(dir, "backup")
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.Equal(t, []string{"run1.1.log.gz", "run1.2.log.gz", "run1.3.log.gz", "run1.4.log.gz", "run1.log"}, runs[0].Segments)
+
+	// all segments concatenated are the complete output
+	sb := strings.Builder{}
+	for _, segment := range runs[0].Segments {
+		f, err := os.Open(filepath.Join(dir, "backup", segment))
+		require.NoError(t, err)
+		var r io.Reader = f
+		if strings.HasSuffix(segment, ".gz") {
+			r, err = gzip.NewReader(f)
+			require.NoError(t, err)
+		}
+		_, err = io.Copy(&sb, r)
+		require.NoError(t, err)
+		f.Close()
+	}
+	require.Equal(t, "line 0\nline 1\nline 2\nline 3\nline 4\nline 5\nline 6\nline 7\nline 8\nline 9\n", sb.String())
+}
+
+func TestFileSinkRetainRuns(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 5; i++ {
+		s, err := output.NewFileSink(dir, "backup", output.WithRunID(fmt.Sprintf("run%d", i)), output.WithRetainRuns(2))
+		require.NoError(t, err)
+		require.NoError(t, s.Close())
+	}
+
+	runs, err := output.ReadIndex(dir, "backup")
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	require.Equal(t, "run3", runs[0].RunID)
+	require.Equal(t, "run4", runs[1].RunID)
+
+	entries, err := os.ReadDir(filepath.Join(dir, "backup"))
+	require.NoError(t, err)
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	require.ElementsMatch(t, []string{"index.jsonl", "index.lock", "run3.log", "run4.log"}, names)
+}
+
+func TestFileSinkRetentionKeepsActiveRuns(t *testing.T) {
+	dir := t.TempDir()
+	active, err := output.NewFileSink(dir, "backup", output.WithRunID("run0"))
+	require.NoError(t, err)
+	// a crashed run: never closed and its log is not locked anymore
+	index, err := os.OpenFile(filepath.Join(dir, "backup", output.IndexFileName), os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(index, `{"runId":"run1","task":"backup","start":%q,"segments":["run1.log"]}`+"\n", time.Now().Format(time.RFC3339Nano))
+	require.NoError(t, err)
+	require.NoError(t, index.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "backup", "run1.log"), []byte("crashed\n"), 0o644))
+
+	s, err := output.NewFileSink(dir, "backup", output.WithRunID("run2"), output.WithRetainRuns(1))
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	runs, err := output.ReadIndex(dir, "backup")
+	require.NoError(t, err)
+	ids := []string{}
+	for _, run := range runs {
+		ids = append(ids, run.RunID)
+	}
+	require.Equal(t, []string{"run0", "run2"}, ids)
+
+	_, err = active.Write([]byte("still running\n"))
+	require.NoError(t, err)
+	require.NoError(t, active.Flush())
+	require.NoError(t, active.Close())
+	require.ErrorIs(t, active.Flush(), output.ErrClosed)
+}
+
+func TestFileSinkConcurrentSinks(t *testing.T) {
+	dir := t.TempDir()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := output.NewFileSink(dir, "backup", output.WithRetainRuns(5))
+			require.NoError(t, err)
+			require.NoError(t, s.Close())
+		}()
+	}
+	wg.Wait()
+
+	// runs still open while others applied retention are kept, but no entry is lost
+	runs, err := output.ReadIndex(dir, "backup")
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(runs), 5)
+	for _, run := range runs {
+		require.True(t, run.Complete())
+		_, err := os.Stat(filepath.Join(dir, "backup", run.RunID+".log"))
+		require.NoError(t, err)
+	}
+
+	s, err := output.NewFileSink(dir, "backup", output.WithRetainRuns(5))
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+	runs, err = output.ReadIndex(dir, "backup")
+	require.NoError(t, err)
+	require.Len(t, runs, 5)
+}
+
+func TestFileSinkInvalidTaskName(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"", "..", "a/b"} {
+		_, err := output.NewFileSink(dir, name)
+		require.ErrorContains(t, err, "invalid task name")
+	}
+	_, err := output.ReadIndex(dir, "../x")
+	require.ErrorContains(t, err, "invalid task name")
+}
+
+func TestFileSinkIgnoresTruncatedIndex(t *testing.T) {
+	dir := t.TempDir()
+	s, err := output.NewFileSink(dir, "backup", output.WithRunID("run1"))
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	// simulate a crash while appending to the index
+	f, err := os.OpenFile(filepath.Join(dir, "backup", output.IndexFileName), os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(`{"runId":"run2","ta`))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	runs, err := output.ReadIndex(dir, "backup")
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+}
@@ -0,0 +1,184 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Default size of the output of a single group kept in memory before it is spilled to disk
+const DefaultSpillThreshold = 1024 * 1024
+
+// Prints the output of parallel tasks as contiguous blocks (like `make -O`)
+//   - every task gets its own group, output is buffered until the group ends
+//   - buffers exceeding the spill threshold are moved to a temporary file
+//   - on End the group is printed in one piece, surrounded by a header and footer
+//     (or GitHub Actions ::group:: markers, see WithGitHubActionsGroups)
+//   - safe for concurrent use
+type grouper struct {
+	mu   sync.Mutex
+	opts grouperOptions
+	sink io.Writer
+}
+
+// Create a grouper printing finished groups to sink
+func NewGrouper(sink io.Writer, opts ...GrouperOption) *grouper {
+	mergedOpts := grouperOptions{
+		spillThreshold: DefaultSpillThreshold,
+	}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+
+	return &grouper{opts: mergedOpts, sink: sink}
+}
+
+// Start a new group, its output is printed once End is called
+func (g *grouper) Group(name string) *group {
+	return &group{
+		grouper: g,
+		name:    name,
+		start:   time.Now(),
+		buf:     &bytes.Buffer{},
+	}
+}
+
+// A group that is ended with the result of its task (see Group)
+type GroupWriter interface {
+	io.Writer
+	End(err error) error
+}
+
+// Same as Group, usable through interfaces (e.g. utask.WithShellGroupedOutput)
+func (g *grouper) TaskGroup(name string) GroupWriter {
+	return g.Group(name)
+}
+
+type group struct {
+	mu      sync.Mutex
+	grouper *grouper
+	name    string
+	start   time.Time
+	buf     *bytes.Buffer
+	file    *os.File
+	ended   bool
+}
+
+func (gr *group) Write(p []byte) (n int, err error) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	if gr.ended {
+		return 0, ErrClosed
+	}
+
+	if gr.file != nil {
+		return gr.file.Write(p)
+	}
+
+	if gr.buf.Len()+len(p) > gr.grouper.opts.spillThreshold {
+		if err := gr.spill(); err != nil {
+			return 0, err
+		}
+		return gr.file.Write(p)
+	}
+
+	return gr.buf.Write(p)
+}
+
+// End the group and print it (header, output and footer) as one block.
+// err is the result of the task and is shown in the footer.
+func (gr *group) End(err error) error {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	if gr.ended {
+		return ErrClosed
+	}
+	gr.ended = true
+
+	// output not terminated with a newline must not end up on the footer's line
+	complete := gr.lastByte() == '\n'
+
+	var body io.Reader = gr.buf
+	if gr.file != nil {
+		defer func() {
+			gr.file.Close()
+			os.Remove(gr.file.Name())
+		}()
+		if _, seekErr := gr.file.Seek(0, io.SeekStart); seekErr != nil {
+			return seekErr
+		}
+		body = gr.file
+	}
+
+	g := gr.grouper
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	duration := FormatDuration(time.Since(gr.start))
+	header := fmt.Sprintf("==> %s\n", gr.name)
+	footer := fmt.Sprintf("<== %s (done in %s)\n", gr.name, duration)
+	if err != nil {
+		footer = fmt.Sprintf("<== %s (failed after %s: %s)\n", gr.name, duration, err)
+	}
+	if g.opts.gitHubActions {
+		header = fmt.Sprintf("::group::%s\n", gr.name)
+		footer = "::endgroup::\n"
+		if err != nil {
+			footer += fmt.Sprintf("::error title=%s::failed after %s: %s\n", gr.name, duration, err)
+		}
+	}
+
+	if _, err := io.WriteString(g.sink, header); err != nil {
+		return err
+	}
+	n, copyErr := io.Copy(g.sink, body)
+	if copyErr != nil {
+		return copyErr
+	}
+	if n > 0 && !complete {
+		footer = "\n" + footer
+	}
+	_, writeErr := io.WriteString(g.sink, footer)
+	return writeErr
+}
+
+// move the in-memory buffer to a temporary file
+func (gr *group) spill() error {
+	f, err := os.CreateTemp(gr.grouper.opts.tempDir, "utask-group-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(gr.buf.Bytes()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	gr.buf = &bytes.Buffer{}
+	gr.file = f
+	return nil
+}
+
+// last byte of the group's output
+func (gr *group) lastByte() byte {
+	if gr.file == nil {
+		b := gr.buf.Bytes()
+		if len(b) == 0 {
+			return 0
+		}
+		return b[len(b)-1]
+	}
+	last := make([]byte, 1)
+	info, err := gr.file.Stat()
+	if err != nil || info.Size() == 0 {
+		return 0
+	}
+	if _, err := gr.file.ReadAt(last, info.Size()-1); err != nil {
+		return 0
+	}
+	return last[0]
+}
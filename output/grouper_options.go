@@ -0,0 +1,47 @@
+package output
+
+type grouperOptions struct {
+	spillThreshold int
+	tempDir        string
+	gitHubActions  bool
+}
+
+// Options for a grouper
+type GrouperOption interface {
+	apply(*grouperOptions)
+}
+
+type funcGrouperOption struct {
+	f func(*grouperOptions)
+}
+
+// nolint:unused
+func (fgo *funcGrouperOption) apply(o *grouperOptions) {
+	fgo.f(o)
+}
+
+func newFuncGrouperOption(f func(*grouperOptions)) *funcGrouperOption {
+	return &funcGrouperOption{f: f}
+}
+
+// Bytes of output kept in memory per group before spilling to disk (default: DefaultSpillThreshold)
+func WithSpillThreshold(n int) GrouperOption {
+	return newFuncGrouperOption(func(o *grouperOptions) {
+		o.spillThreshold = n
+	})
+}
+
+// Directory for spilled output (default: os.TempDir())
+func WithSpillDir(dir string) GrouperOption {
+	return newFuncGrouperOption(func(o *grouperOptions) {
+		o.tempDir = dir
+	})
+}
+
+// Surround groups with GitHub Actions ::group:: / ::endgroup:: markers instead of
+// a header and footer, failures are additionally reported with ::error::
+func WithGitHubActionsGroups() GrouperOption {
+	return newFuncGrouperOption(func(o *grouperOptions) {
+		o.gitHubActions = true
+	})
+}
@@ -0,0 +1,78 @@
+package output_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dunv/utask/output"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrouperContiguousBlocks(t *testing.T) {
+	buf := &bytes.Buffer{}
+	g := output.NewGrouper(buf)
+	a := g.Group("a")
+	b := g.Group("b")
+
+	_, _ = a.Write([]byte("a1\n"))
+	_, _ = b.Write([]byte("b1\n"))
+	_, _ = a.Write([]byte("a2\n"))
+	_, _ = b.Write([]byte("b2"))
+	require.NoError(t, b.End(errors.New("exit status 1")))
+	require.NoError(t, a.End(nil))
+
+	lines := strings.Split(buf.String(), "\n")
+	require.Equal(t, "==> b", lines[0])
+	require.Equal(t, "b1", lines[1])
+	require.Equal(t, "b2", lines[2])
+	require.Regexp(t, `^<== b \(failed after .+: exit status 1\)$`, lines[3])
+	require.Equal(t, "==> a", lines[4])
+	require.Equal(t, "a1", lines[5])
+	require.Equal(t, "a2", lines[6])
+	require.Regexp(t, `^<== a \(done in .+\)$`, lines[7])
+
+	_, err := a.Write([]byte("after end\n"))
+	require.ErrorIs(t, err, output.ErrClosed)
+	require.ErrorIs(t, a.End(nil), output.ErrClosed)
+}
+
+func TestGrouperGitHubActions(t *testing.T) {
+	buf := &bytes.Buffer{}
+	g := output.NewGrouper(buf, output.WithGitHubActionsGroups())
+	a := g.Group("build")
+	_, _ = a.Write([]byte("compiling\n"))
+	require.NoError(t, a.End(errors.New("exit status 2")))
+	require.Regexp(t, `^::group::build\ncompiling\n::endgroup::\n::error title=build::failed after .+: exit status 2\n$`, buf.String())
+}
+
+func TestGrouperSpillToDisk(t *testing.T) {
+	dir := t.TempDir()
+	buf := &bytes.Buffer{}
+	g := output.NewGrouper(buf, output.WithSpillThreshold(10), output.WithSpillDir(dir))
+	a := g.Group("a")
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = a.Write([]byte("some output line\n"))
+		}()
+	}
+	wg.Wait()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	require.NoError(t, a.End(nil))
+	require.Equal(t, 10, strings.Count(buf.String(), "some output line\n"))
+
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 0)
+}
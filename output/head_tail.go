@@ -0,0 +1,197 @@
+package output
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Bounded capture of the first and last part of an output
+//   - keeps the first head and the last tail lines (or bytes)
+//   - counts everything in between instead of keeping it
+//   - safe for concurrent use
+type headTailCapture struct {
+	mu       sync.Mutex
+	bytes    bool
+	head     int
+	tail     int
+	omitted  uint64
+	splitter *lineSplitter
+
+	// lines mode
+	headLines []string
+	tailLines []string // ring buffer, oldest line at tailStart
+	tailStart int
+
+	// bytes mode
+	headBytes []byte
+	tailBytes []byte // ring buffer, oldest byte at tailStart
+}
+
+// Capture the first head and the last tail lines written.
+// Lines longer than DefaultMaxLineLength are split.
+func NewHeadTailLines(head, tail int) *headTailCapture {
+	c := &headTailCapture{head: head, tail: tail}
+	lineOpts := lineOptions{preserveWhitespace: true, keepEmptyLines: true}
+	c.splitter = newLineSplitter(DefaultMaxLineLength, false, func(line []byte) {
+		if text, ok := lineOpts.process(line); ok {
+			c.addLine(text)
+		}
+	})
+	return c
+}
+
+// Capture the first head and the last tail bytes written
+func NewHeadTailBytes(head, tail int) *headTailCapture {
+	return &headTailCapture{bytes: true, head: head, tail: tail}
+}
+
+func (c *headTailCapture) Write(p []byte) (n int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.bytes {
+		c.addBytes(p)
+	} else {
+		c.splitter.write(p)
+	}
+	return len(p), nil
+}
+
+// Capture a pending partial line (lines mode only)
+func (c *headTailCapture) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.bytes {
+		c.splitter.flush()
+	}
+	return nil
+}
+
+// Number of lines (or bytes) dropped between head and tail
+func (c *headTailCapture) Omitted() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.omitted
+}
+
+// Captured lines, including a marker line if lines were omitted (lines mode only)
+func (c *headTailCapture) Lines() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lines := append([]string{}, c.headLines...)
+	if c.omitted > 0 {
+		lines = append(lines, omittedMarker(c.omitted, "line"))
+	}
+	lines = append(lines, c.tailLines[c.tailStart:]...)
+	return append(lines, c.tailLines[:c.tailStart]...)
+}
+
+// Captured first lines (lines mode only)
+func (c *headTailCapture) Head() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string{}, c.headLines...)
+}
+
+// Captured last lines (lines mode only)
+func (c *headTailCapture) Tail() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tail := append([]string{}, c.tailLines[c.tailStart:]...)
+	return append(tail, c.tailLines[:c.tailStart]...)
+}
+
+// Render the captured output with a marker like "... 1,234,567 lines omitted ..."
+// in place of the dropped part
+func (c *headTailCapture) String() string {
+	if !c.bytes {
+		lines := c.Lines()
+		if len(lines) == 0 {
+			return ""
+		}
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sb := strings.Builder{}
+	sb.Write(c.headBytes)
+	if c.omitted > 0 {
+		if len(c.headBytes) > 0 && c.headBytes[len(c.headBytes)-1] != '\n' {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(omittedMarker(c.omitted, "byte"))
+		sb.WriteByte('\n')
+	}
+	sb.Write(c.tailBytes[c.tailStart:])
+	sb.Write(c.tailBytes[:c.tailStart])
+	return sb.String()
+}
+
+func (c *headTailCapture) addLine(line string) {
+	if len(c.headLines) < c.head {
+		c.headLines = append(c.headLines, line)
+		return
+	}
+	if c.tail <= 0 {
+		c.omitted++
+		return
+	}
+	if len(c.tailLines) < c.tail {
+		c.tailLines = append(c.tailLines, line)
+		return
+	}
+	c.tailLines[c.tailStart] = line
+	c.tailStart = (c.tailStart + 1) % c.tail
+	c.omitted++
+}
+
+func (c *headTailCapture) addBytes(p []byte) {
+	if free := c.head - len(c.headBytes); free > 0 {
+		n := min(free, len(p))
+		c.headBytes = append(c.headBytes, p[:n]...)
+		p = p[n:]
+	}
+	if c.tail <= 0 {
+		c.omitted += uint64(len(p))
+		return
+	}
+	if len(p) > c.tail {
+		c.omitted += uint64(len(p) - c.tail)
+		p = p[len(p)-c.tail:]
+	}
+	if free := c.tail - len(c.tailBytes); free > 0 {
+		n := min(free, len(p))
+		c.tailBytes = append(c.tailBytes, p[:n]...)
+		p = p[n:]
+	}
+	for _, b := range p {
+		c.tailBytes[c.tailStart] = b
+		c.tailStart = (c.tailStart + 1) % c.tail
+		c.omitted++
+	}
+}
+
+func omittedMarker(n uint64, unit string) string {
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("... %s %s omitted ...", formatThousands(n), unit)
+}
+
+// 1234567 -> 1,234,567
+func formatThousands(n uint64) string {
+	s := strconv.FormatUint(n, 10)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
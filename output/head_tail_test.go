@@ -0,0 +1,62 @@
+package output_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dunv/utask/output"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadTailLines(t *testing.T) {
+	c := output.NewHeadTailLines(2, 3)
+	for i := 1; i <= 1234567; i++ {
+		_, _ = fmt.Fprintf(c, "%d\n", i)
+	}
+	require.Equal(t, uint64(1234562), c.Omitted())
+	require.Equal(t, []string{
+		"1",
+		"2",
+		"... 1,234,562 lines omitted ...",
+		"1234565",
+		"1234566",
+		"1234567",
+	}, c.Lines())
+}
+
+func TestHeadTailLinesNothingOmitted(t *testing.T) {
+	c := output.NewHeadTailLines(2, 3)
+	_, _ = c.Write([]byte("1\n2\n3\npartial"))
+	require.NoError(t, c.Flush())
+	require.Equal(t, uint64(0), c.Omitted())
+	require.Equal(t, "1\n2\n3\npartial\n", c.String())
+}
+
+func TestHeadTailLinesOnlyTail(t *testing.T) {
+	c := output.NewHeadTailLines(0, 2)
+	_, _ = c.Write([]byte("1\n2\n3\n"))
+	require.Equal(t, []string{"... 1 line omitted ...", "2", "3"}, c.Lines())
+}
+
+func TestHeadTailBytes(t *testing.T) {
+	c := output.NewHeadTailBytes(4, 4)
+	_, _ = c.Write([]byte("abc"))
+	_, _ = c.Write([]byte(strings.Repeat("x", 1000)))
+	_, _ = c.Write([]byte("wxyz"))
+	require.Equal(t, uint64(999), c.Omitted())
+	require.Equal(t, "abcx\n... 999 bytes omitted ...\nwxyz", c.String())
+}
+
+func TestHeadTailBytesSmallWrites(t *testing.T) {
+	c := output.NewHeadTailBytes(2, 3)
+	for _, b := range []byte("0123456789") {
+		_, _ = c.Write([]byte{b})
+	}
+	require.Equal(t, uint64(5), c.Omitted())
+	require.Equal(t, "01\n... 5 bytes omitted ...\n789", c.String())
+
+	c = output.NewHeadTailBytes(1, 1)
+	_, _ = c.Write([]byte("abc"))
+	require.Equal(t, "a\n... 1 byte omitted ...\nc", c.String())
+}
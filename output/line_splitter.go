@@ -0,0 +1,85 @@
+package output
+
+import "bytes"
+
+// Default for the maximum length of a single line, longer lines are split
+const DefaultMaxLineLength = 64 * 1024
+
+// helper for splitting a stream of bytes into lines
+//   - emit is called for every complete line (without '\n')
+//   - memory is bounded: lines longer than maxLineLength are emitted in parts
+//   - optionally '\r' terminates a line as well ("\r\n" is a single terminator)
+//   - not safe for concurrent use
+type lineSplitter struct {
+	buf           []byte
+	maxLineLength int
+	splitOnCR     bool
+	skipLF        bool
+	emit          func(line []byte)
+}
+
+func newLineSplitter(maxLineLength int, splitOnCR bool, emit func(line []byte)) *lineSplitter {
+	if maxLineLength <= 0 {
+		maxLineLength = DefaultMaxLineLength
+	}
+	return &lineSplitter{maxLineLength: maxLineLength, splitOnCR: splitOnCR, emit: emit}
+}
+
+func (s *lineSplitter) write(p []byte) {
+	for len(p) > 0 {
+		if s.skipLF {
+			s.skipLF = false
+			if p[0] == '\n' {
+				p = p[1:]
+				continue
+			}
+		}
+
+		i := s.indexTerminator(p)
+		if i < 0 {
+			s.buf = append(s.buf, p...)
+			s.emitOverlong()
+			return
+		}
+
+		if len(s.buf) == 0 && i <= s.maxLineLength {
+			// fast path: line is completely contained in p
+			s.emit(p[:i])
+		} else {
+			s.buf = append(s.buf, p[:i]...)
+			s.emitOverlong()
+			s.emit(s.buf)
+			s.buf = s.buf[:0]
+		}
+		s.skipLF = p[i] == '\r'
+		p = p[i+1:]
+	}
+}
+
+func (s *lineSplitter) indexTerminator(p []byte) int {
+	if s.splitOnCR {
+		return bytes.IndexAny(p, "\r\n")
+	}
+	return bytes.IndexByte(p, '\n')
+}
+
+// emit the partial line (if any)
+func (s *lineSplitter) flush() {
+	if len(s.buf) > 0 {
+		s.emit(s.buf)
+		s.buf = s.buf[:0]
+	}
+}
+
+// emit parts of maxLineLength as long as the buffer exceeds it, so at most
+// maxLineLength bytes are kept in the buffer
+func (s *lineSplitter) emitOverlong() {
+	start := 0
+	for len(s.buf)-start > s.maxLineLength {
+		s.emit(s.buf[start : start+s.maxLineLength])
+		start += s.maxLineLength
+	}
+	if start > 0 {
+		s.buf = append(s.buf[:0], s.buf[start:]...)
+	}
+}
@@ -0,0 +1,64 @@
+package output
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// How carriage returns ('\r') within a line are treated.
+// A '\r' directly before '\n' is always part of the line ending.
+type CarriageReturnMode int
+
+const (
+	// Keep '\r' as part of the line (default)
+	CRKeep CarriageReturnMode = iota
+	// Treat '\r' as a line terminator, every update of a progress bar becomes a line
+	CRAsTerminator
+	// Only keep what follows the last '\r' of a line, i.e. the last update of a progress bar
+	CRCollapse
+)
+
+// matches CSI sequences (colors, cursor movement), OSC sequences (window titles, links),
+// charset designations (e.g. "ESC ( B" written by tput sgr0) and the remaining two-byte escape sequences
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[()*+].|\x1b[@-Z\\-_]`)
+
+// Remove ANSI escape sequences from s
+func StripANSI(s string) string {
+	return ansiEscape.ReplaceAllString(s, "")
+}
+
+// semantics applied to every line before it is published
+type lineOptions struct {
+	preserveWhitespace bool
+	keepEmptyLines     bool
+	carriageReturn     CarriageReturnMode
+	stripANSI          bool
+}
+
+// lines as they were written (only the line ending is removed)
+var rawLineOptions = lineOptions{preserveWhitespace: true, keepEmptyLines: true}
+
+// returns the line to be published and whether it should be published at all
+func (o lineOptions) process(line []byte) (string, bool) {
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+
+	if o.carriageReturn == CRCollapse {
+		if i := bytes.LastIndexByte(line, '\r'); i >= 0 {
+			line = line[i+1:]
+		}
+	}
+
+	if o.stripANSI && bytes.IndexByte(line, '\x1b') >= 0 {
+		line = ansiEscape.ReplaceAll(line, nil)
+	}
+
+	if !o.preserveWhitespace {
+		line = bytes.TrimSpace(line)
+	}
+
+	if len(line) == 0 && !o.keepEmptyLines {
+		return "", false
+	}
+
+	return string(line), true
+}
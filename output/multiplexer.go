@@ -0,0 +1,145 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// colors used for prefixes, in order of assignment (cyan, yellow, green, magenta, blue and their bright variants)
+var prefixColors = []string{"36", "33", "32", "35", "34", "96", "93", "92", "95", "94"}
+
+// Writes the output of multiple tasks into a single sink (like `docker compose up`)
+//   - every task gets its own writer with a name prefix, aligned to the longest name
+//   - the prefix width is fixed when the first line is written, create all writers before
+//     that (or use WithMultiplexerPrefixWidth) to keep later prefixes aligned
+//   - prefixes are colored if the sink is a terminal (see WithMultiplexerColors)
+//   - lines are written whole, lines of different tasks never interleave
+//   - safe for concurrent use
+type multiplexer struct {
+	mu     sync.Mutex
+	opts   multiplexerOptions
+	sink   io.Writer
+	colors bool
+	width  int
+	count  int
+	frozen bool // true once the first line was written, width does not change anymore
+}
+
+// Create a multiplexer writing to sink
+func NewMultiplexer(sink io.Writer, opts ...MultiplexerOption) *multiplexer {
+	mergedOpts := multiplexerOptions{}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+
+	colors := isTerminal(sink) && os.Getenv("NO_COLOR") == ""
+	if mergedOpts.colors != nil {
+		colors = *mergedOpts.colors
+	}
+
+	return &multiplexer{
+		opts:   mergedOpts,
+		sink:   sink,
+		colors: colors,
+		width:  mergedOpts.prefixWidth,
+	}
+}
+
+// Writer for a single task, lines are prefixed with name.
+// A pending partial line is written by Flush.
+func (m *multiplexer) Writer(name string) *prefixWriter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.frozen && len(name) > m.width {
+		m.width = len(name)
+	}
+
+	w := &prefixWriter{
+		mux:   m,
+		name:  name,
+		color: prefixColors[m.count%len(prefixColors)],
+	}
+	m.count++
+	w.splitter = newLineSplitter(DefaultMaxLineLength, false, w.writeLine)
+	return w
+}
+
+// format and write a single line, called with the writers lock held
+func (m *multiplexer) writeLine(w *prefixWriter, line string) error {
+	sb := strings.Builder{}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.frozen = true
+	if m.opts.timeLayout != "" {
+		sb.WriteString(time.Now().Format(m.opts.timeLayout))
+		sb.WriteByte(' ')
+	}
+	prefix := fmt.Sprintf("%-*s |", m.width, w.name)
+	if m.colors {
+		prefix = "\x1b[" + w.color + "m" + prefix + "\x1b[0m"
+	}
+	sb.WriteString(prefix)
+	if len(line) > 0 {
+		sb.WriteByte(' ')
+		sb.WriteString(line)
+	}
+	sb.WriteByte('\n')
+
+	_, err := io.WriteString(m.sink, sb.String())
+	return err
+}
+
+type prefixWriter struct {
+	mu       sync.Mutex
+	mux      *multiplexer
+	name     string
+	color    string
+	splitter *lineSplitter
+	err      error
+}
+
+func (w *prefixWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.err = nil
+	w.splitter.write(p)
+	return len(p), w.err
+}
+
+// Write a pending partial line (if any)
+func (w *prefixWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.err = nil
+	w.splitter.flush()
+	return w.err
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	text, _ := rawLineOptions.process(line)
+	if err := w.mux.writeLine(w, text); err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+// true if w is a terminal (i.e. its terminal attributes can be read)
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	termios := syscall.Termios{}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+	return errno == 0
+}
@@ -0,0 +1,46 @@
+package output
+
+type multiplexerOptions struct {
+	colors      *bool
+	timeLayout  string
+	prefixWidth int
+}
+
+// Options for a multiplexer
+type MultiplexerOption interface {
+	apply(*multiplexerOptions)
+}
+
+type funcMultiplexerOption struct {
+	f func(*multiplexerOptions)
+}
+
+// nolint:unused
+func (fmo *funcMultiplexerOption) apply(o *multiplexerOptions) {
+	fmo.f(o)
+}
+
+func newFuncMultiplexerOption(f func(*multiplexerOptions)) *funcMultiplexerOption {
+	return &funcMultiplexerOption{f: f}
+}
+
+// Force colored prefixes on or off (default: colored if the sink is a terminal and NO_COLOR is not set)
+func WithMultiplexerColors(colors bool) MultiplexerOption {
+	return newFuncMultiplexerOption(func(o *multiplexerOptions) {
+		o.colors = &colors
+	})
+}
+
+// Prefix every line with the current time, formatted with layout (e.g. time.TimeOnly)
+func WithMultiplexerTimestamps(layout string) MultiplexerOption {
+	return newFuncMultiplexerOption(func(o *multiplexerOptions) {
+		o.timeLayout = layout
+	})
+}
+
+// Minimum width of the name prefix (default: length of the longest name known when the first line is written)
+func WithMultiplexerPrefixWidth(width int) MultiplexerOption {
+	return newFuncMultiplexerOption(func(o *multiplexerOptions) {
+		o.prefixWidth = width
+	})
+}
@@ -0,0 +1,86 @@
+package output_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dunv/utask/output"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiplexerPrefixes(t *testing.T) {
+	buf := &bytes.Buffer{}
+	mux := output.NewMultiplexer(buf)
+	web := mux.Writer("web")
+	worker := mux.Writer("worker")
+
+	_, _ = web.Write([]byte("listening"))
+	_, _ = worker.Write([]byte("started\n\n"))
+	_, _ = web.Write([]byte(" on :8080\r\n"))
+	_, _ = worker.Write([]byte("partial"))
+	require.NoError(t, worker.Flush())
+
+	// not a terminal: no colors
+	require.Equal(t, strings.Join([]string{
+		"worker | started",
+		"worker |",
+		"web    | listening on :8080",
+		"worker | partial",
+	}, "\n")+"\n", buf.String())
+}
+
+func TestMultiplexerColorsAndTimestamps(t *testing.T) {
+	buf := &bytes.Buffer{}
+	mux := output.NewMultiplexer(buf,
+		output.WithMultiplexerColors(true),
+		output.WithMultiplexerTimestamps(time.TimeOnly),
+		output.WithMultiplexerPrefixWidth(5),
+	)
+	_, _ = mux.Writer("a").Write([]byte("first\n"))
+	_, _ = mux.Writer("b").Write([]byte("second\n"))
+	require.Regexp(t, "^\\d\\d:\\d\\d:\\d\\d \x1b\\[36ma     \\|\x1b\\[0m first\n\\d\\d:\\d\\d:\\d\\d \x1b\\[33mb     \\|\x1b\\[0m second\n$", buf.String())
+}
+
+func TestMultiplexerWholeLines(t *testing.T) {
+	buf := &bytes.Buffer{}
+	mux := output.NewMultiplexer(buf)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		w := mux.Writer(fmt.Sprintf("task%d", i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				// write lines in pieces
+				_, _ = w.Write([]byte("some "))
+				_, _ = w.Write([]byte("output\n"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 1000)
+	for _, line := range lines {
+		require.Regexp(t, `^task\d \| some output$`, line)
+	}
+}
+
+func TestMultiplexerWidthFixedAfterFirstLine(t *testing.T) {
+	buf := &bytes.Buffer{}
+	mux := output.NewMultiplexer(buf)
+	web := mux.Writer("web")
+	_, _ = web.Write([]byte("first\n"))
+	_, _ = mux.Writer("worker").Write([]byte("started\n"))
+	_, _ = web.Write([]byte("second\n"))
+
+	require.Equal(t, strings.Join([]string{
+		"web | first",
+		"worker | started",
+		"web | second",
+	}, "\n")+"\n", buf.String())
+}
@@ -0,0 +1,222 @@
+package output
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Stream a line was written to
+type Stream string
+
+const (
+	Stdout Stream = "stdout"
+	Stderr Stream = "stderr"
+	// Banners printed at the start and end of a task
+	Lifecycle Stream = "lifecycle"
+)
+
+// A single recorded line
+type Line struct {
+	// Position in the recording (starting at 0), reflects the order lines were recorded in
+	Seq    uint64 `json:"seq"`
+	Stream Stream `json:"stream"`
+	// Wall clock time the line was recorded at
+	Time time.Time `json:"time"`
+	// Time since the recorder was created (monotonic, never decreases with Seq)
+	Elapsed time.Duration `json:"elapsed"`
+	Text    string        `json:"text"`
+}
+
+// Thread-safe recorder for the output of a task
+//   - lines are tagged with their stream, timestamped and numbered when completed
+//   - lines of all streams are kept in a single ordered log, which can be exported
+//     as JSON Lines (WriteJSONLines) or plain text (WriteText)
+//   - snapshots do not consume recorded lines
+//   - any number of subscribers get a replay of recorded lines followed by live updates
+type recorder struct {
+	mu       sync.Mutex
+	opts     recorderOptions
+	lines    []Line
+	first    uint64 // number of lines discarded because of maxLines
+	writers  []*recorderWriter
+	notify   chan struct{}
+	closed   bool
+	lineOpts lineOptions
+	start    time.Time
+}
+
+// Create a recorder (see Writer, Snapshot and Subscribe)
+func NewRecorder(opts ...RecorderOption) *recorder {
+	mergedOpts := recorderOptions{
+		maxLineLength: DefaultMaxLineLength,
+	}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+
+	return &recorder{
+		opts:     mergedOpts,
+		notify:   make(chan struct{}),
+		lineOpts: lineOptions{preserveWhitespace: true, keepEmptyLines: true},
+		start:    time.Now(),
+	}
+}
+
+// Writer recording lines for the given stream. Partial lines are recorded once
+// they are completed or the recorder is closed.
+func (r *recorder) Writer(stream Stream) io.Writer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w := &recorderWriter{recorder: r}
+	w.splitter = newLineSplitter(r.opts.maxLineLength, false, func(line []byte) {
+		if text, ok := r.lineOpts.process(line); ok {
+			r.appendLocked(stream, text)
+		}
+	})
+	r.writers = append(r.writers, w)
+	return w
+}
+
+// Shorthand for Writer(Stdout)
+func (r *recorder) Stdout() io.Writer {
+	return r.Writer(Stdout)
+}
+
+// Shorthand for Writer(Stderr)
+func (r *recorder) Stderr() io.Writer {
+	return r.Writer(Stderr)
+}
+
+// Copy of all recorded lines
+func (r *recorder) Snapshot() []Line {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Line{}, r.lines...)
+}
+
+// Number of lines discarded because of WithRecorderMaxLines
+func (r *recorder) Discarded() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.first
+}
+
+// Subscribe to recorded lines. The returned channel first receives all lines
+// recorded so far and then live updates. It is closed once the recorder is
+// closed and all lines have been delivered, or once ctx is done.
+// A subscriber which is slower than WithRecorderMaxLines misses lines.
+func (r *recorder) Subscribe(ctx context.Context) <-chan Line {
+	ch := make(chan Line)
+	go func() {
+		defer close(ch)
+
+		next := uint64(0)
+		for {
+			r.mu.Lock()
+			if next < r.first {
+				next = r.first
+			}
+			pending := append([]Line{}, r.lines[next-r.first:]...)
+			notify, closed := r.notify, r.closed
+			r.mu.Unlock()
+
+			for _, line := range pending {
+				select {
+				case ch <- line:
+					next++
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if len(pending) > 0 {
+				continue
+			}
+
+			if closed {
+				return
+			}
+
+			select {
+			case <-notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// Record pending partial lines and end all subscriptions (after delivering
+// remaining lines). Writing after Close returns ErrClosed.
+func (r *recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return ErrClosed
+	}
+
+	for _, w := range r.writers {
+		w.splitter.flush()
+	}
+	r.closed = true
+	close(r.notify)
+	return nil
+}
+
+func (r *recorder) appendLocked(stream Stream, text string) {
+	now := time.Now()
+	r.lines = append(r.lines, Line{
+		Seq:     r.first + uint64(len(r.lines)),
+		Stream:  stream,
+		Time:    now,
+		Elapsed: now.Sub(r.start),
+		Text:    text,
+	})
+	if r.opts.maxLines > 0 && len(r.lines) > r.opts.maxLines {
+		// reslicing is O(1), append moves the kept lines into a new array once the old one is full
+		drop := len(r.lines) - r.opts.maxLines
+		r.lines = r.lines[drop:]
+		r.first += uint64(drop)
+	}
+
+	// wake up all subscribers
+	close(r.notify)
+	r.notify = make(chan struct{})
+}
+
+type recorderWriter struct {
+	recorder *recorder
+	splitter *lineSplitter
+}
+
+// Record a pending partial line (if any)
+func (w *recorderWriter) Flush() error {
+	w.recorder.mu.Lock()
+	defer w.recorder.mu.Unlock()
+
+	if w.recorder.closed {
+		return ErrClosed
+	}
+
+	w.splitter.flush()
+	return nil
+}
+
+func (w *recorderWriter) Write(p []byte) (n int, err error) {
+	w.recorder.mu.Lock()
+	defer w.recorder.mu.Unlock()
+
+	if w.recorder.closed {
+		return 0, ErrClosed
+	}
+
+	w.splitter.write(p)
+	return len(p), nil
+}
@@ -0,0 +1,93 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Write all recorded lines as JSON Lines (one JSON encoded Line per line)
+func (r *recorder) WriteJSONLines(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, line := range r.Snapshot() {
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write all recorded lines as plain text, optionally with prefixes
+// (see WithTimePrefix, WithElapsedPrefix and WithStreamPrefix)
+func (r *recorder) WriteText(w io.Writer, opts ...TextOption) error {
+	mergedOpts := textOptions{}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, line := range r.Snapshot() {
+		if mergedOpts.timeLayout != "" {
+			fmt.Fprintf(bw, "%s ", line.Time.Format(mergedOpts.timeLayout))
+		}
+		if mergedOpts.elapsed {
+			fmt.Fprintf(bw, "%10.3f ", line.Elapsed.Seconds())
+		}
+		if mergedOpts.stream {
+			fmt.Fprintf(bw, "%-9s | ", line.Stream)
+		}
+		bw.WriteString(line.Text)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+type textOptions struct {
+	timeLayout string
+	elapsed    bool
+	stream     bool
+}
+
+// Options for rendering recorded lines as text
+type TextOption interface {
+	apply(*textOptions)
+}
+
+type funcTextOption struct {
+	f func(*textOptions)
+}
+
+// nolint:unused
+func (fto *funcTextOption) apply(o *textOptions) {
+	fto.f(o)
+}
+
+func newFuncTextOption(f func(*textOptions)) *funcTextOption {
+	return &funcTextOption{f: f}
+}
+
+// Prefix every line with the time it was recorded at, formatted with layout (e.g. time.RFC3339Nano)
+func WithTimePrefix(layout string) TextOption {
+	return newFuncTextOption(func(o *textOptions) {
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		o.timeLayout = layout
+	})
+}
+
+// Prefix every line with the seconds elapsed since the recorder was created
+func WithElapsedPrefix() TextOption {
+	return newFuncTextOption(func(o *textOptions) {
+		o.elapsed = true
+	})
+}
+
+// Prefix every line with the stream it was written to
+func WithStreamPrefix() TextOption {
+	return newFuncTextOption(func(o *textOptions) {
+		o.stream = true
+	})
+}
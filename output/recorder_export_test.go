@@ -0,0 +1,54 @@
+package output_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/dunv/utask/output"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderWriteJSONLines(t *testing.T) {
+	r := output.NewRecorder()
+	_, _ = r.Writer(output.Lifecycle).Write([]byte("start\n"))
+	_, _ = r.Stdout().Write([]byte("out\n"))
+	_, _ = r.Stderr().Write([]byte("err\n"))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, r.WriteJSONLines(buf))
+
+	lines := []output.Line{}
+	sc := bufio.NewScanner(buf)
+	for sc.Scan() {
+		line := output.Line{}
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	requireLines(t, lines, "lifecycle:start", "stdout:out", "stderr:err")
+	for i, line := range lines {
+		require.Equal(t, uint64(i), line.Seq)
+		if i > 0 {
+			require.GreaterOrEqual(t, line.Elapsed, lines[i-1].Elapsed)
+		}
+	}
+}
+
+func TestRecorderWriteText(t *testing.T) {
+	r := output.NewRecorder()
+	_, _ = r.Stdout().Write([]byte("out\n"))
+	_, _ = r.Stderr().Write([]byte("err\n"))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, r.WriteText(buf))
+	require.Equal(t, "out\nerr\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, r.WriteText(buf, output.WithStreamPrefix()))
+	require.Equal(t, "stdout    | out\nstderr    | err\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, r.WriteText(buf, output.WithElapsedPrefix(), output.WithTimePrefix("")))
+	require.Regexp(t, `^\S+ +\d+\.\d{3} out\n\S+ +\d+\.\d{3} err\n$`, buf.String())
+}
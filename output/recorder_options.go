@@ -0,0 +1,38 @@
+package output
+
+type recorderOptions struct {
+	maxLines      int
+	maxLineLength int
+}
+
+// Options for a recorder
+type RecorderOption interface {
+	apply(*recorderOptions)
+}
+
+type funcRecorderOption struct {
+	f func(*recorderOptions)
+}
+
+// nolint:unused
+func (fro *funcRecorderOption) apply(o *recorderOptions) {
+	fro.f(o)
+}
+
+func newFuncRecorderOption(f func(*recorderOptions)) *funcRecorderOption {
+	return &funcRecorderOption{f: f}
+}
+
+// Only keep the last n lines (default: 0, keep all lines)
+func WithRecorderMaxLines(n int) RecorderOption {
+	return newFuncRecorderOption(func(o *recorderOptions) {
+		o.maxLines = n
+	})
+}
+
+// Maximum length of a line in bytes, longer lines are split (default: DefaultMaxLineLength)
+func WithRecorderMaxLineLength(n int) RecorderOption {
+	return newFuncRecorderOption(func(o *recorderOptions) {
+		o.maxLineLength = n
+	})
+}
@@ -0,0 +1,106 @@
+package output_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dunv/utask/output"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderSnapshot(t *testing.T) {
+	r := output.NewRecorder()
+	stdout, stderr := r.Stdout(), r.Stderr()
+	_, _ = stdout.Write([]byte("  out1\n\nout"))
+	_, _ = stderr.Write([]byte("err1\n"))
+	_, _ = stdout.Write([]byte("2\n"))
+
+	// snapshots do not consume lines
+	for i := 0; i < 2; i++ {
+		requireLines(t, r.Snapshot(),
+			"stdout:  out1",
+			"stdout:",
+			"stderr:err1",
+			"stdout:out2",
+		)
+	}
+
+	_, _ = stdout.Write([]byte("partial"))
+	require.NoError(t, r.Close())
+	require.Len(t, r.Snapshot(), 5)
+	_, err := stdout.Write([]byte("after close\n"))
+	require.ErrorIs(t, err, output.ErrClosed)
+}
+
+func TestRecorderMaxLines(t *testing.T) {
+	r := output.NewRecorder(output.WithRecorderMaxLines(2))
+	_, _ = r.Stdout().Write([]byte("1\n2\n3\n"))
+	requireLines(t, r.Snapshot(), "stdout:2", "stdout:3")
+	require.Equal(t, uint64(1), r.Discarded())
+}
+
+func TestRecorderSubscribeReplayAndLive(t *testing.T) {
+	r := output.NewRecorder()
+	stdout := r.Stdout()
+	_, _ = stdout.Write([]byte("before1\nbefore2\n"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	subscribers := []<-chan output.Line{r.Subscribe(ctx), r.Subscribe(ctx)}
+	results := make([][]output.Line, len(subscribers))
+	wg := sync.WaitGroup{}
+	for i, sub := range subscribers {
+		wg.Add(1)
+		go func(i int, sub <-chan output.Line) {
+			defer wg.Done()
+			for line := range sub {
+				results[i] = append(results[i], line)
+			}
+		}(i, sub)
+	}
+
+	for i := 0; i < 100; i++ {
+		_, _ = stdout.Write([]byte("live\n"))
+	}
+	require.NoError(t, r.Close())
+	wg.Wait()
+
+	for _, result := range results {
+		require.Len(t, result, 102)
+		require.Equal(t, "before1", result[0].Text)
+		require.Equal(t, "before2", result[1].Text)
+		require.Equal(t, "live", result[101].Text)
+	}
+
+	// late join after close still replays everything
+	late := []output.Line{}
+	for line := range r.Subscribe(ctx) {
+		late = append(late, line)
+	}
+	require.Len(t, late, 102)
+}
+
+func TestRecorderUnsubscribe(t *testing.T) {
+	r := output.NewRecorder()
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := r.Subscribe(ctx)
+	cancel()
+	select {
+	case _, ok := <-sub:
+		require.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("subscription not ended")
+	}
+}
+
+// test-helper for comparing recorded lines in the form "stream:text"
+func requireLines(t *testing.T, lines []output.Line, expected ...string) {
+	actual := []string{}
+	for _, line := range lines {
+		actual = append(actual, string(line.Stream)+":"+line.Text)
+	}
+	require.Equal(t, expected, actual)
+}
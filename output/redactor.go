@@ -0,0 +1,197 @@
+package output
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Replacement for masked secrets
+const Redacted = "***"
+
+// secrets shorter than this are not searched for in base64 encoded form, as
+// the stable part of their encoding would be too short to be meaningful
+const minBase64SecretLength = 4
+
+// Masks secrets in strings and output
+//   - literal secrets are masked as they are, base64 encoded (standard and URL alphabet,
+//     also when embedded in a longer encoded string) and URL encoded
+//   - patterns are masked completely, or only their capture groups if they have any
+type redactor struct {
+	replacer *strings.Replacer
+	patterns []*regexp.Regexp
+	// all masked literal forms of the secrets, longest first
+	variants []string
+}
+
+// Create a redactor for the given literal secrets and patterns
+func NewRedactor(secrets []string, patterns []*regexp.Regexp) *redactor {
+	variants := map[string]bool{}
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		variants[secret] = true
+		variants[url.QueryEscape(secret)] = true
+		variants[url.PathEscape(secret)] = true
+		variants[base64.StdEncoding.EncodeToString([]byte(secret))] = true
+		variants[base64.URLEncoding.EncodeToString([]byte(secret))] = true
+		if len(secret) >= minBase64SecretLength {
+			for _, enc := range []*base64.Encoding{base64.RawStdEncoding, base64.RawURLEncoding} {
+				for _, v := range base64Variants(enc, secret) {
+					variants[v] = true
+				}
+			}
+		}
+	}
+
+	// longest first, so a secret containing another one is masked completely
+	sorted := []string{}
+	for v := range variants {
+		sorted = append(sorted, v)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i]) != len(sorted[j]) {
+			return len(sorted[i]) > len(sorted[j])
+		}
+		return sorted[i] < sorted[j]
+	})
+
+	oldnew := []string{}
+	for _, v := range sorted {
+		oldnew = append(oldnew, v, Redacted)
+	}
+
+	return &redactor{
+		replacer: strings.NewReplacer(oldnew...),
+		patterns: patterns,
+		variants: sorted,
+	}
+}
+
+// Mask all secrets in s
+func (r *redactor) Redact(s string) string {
+	s = r.replacer.Replace(s)
+	for _, p := range r.patterns {
+		s = redactPattern(p, s)
+	}
+	return s
+}
+
+// Writer masking secrets before passing output on to w.
+// Output is line-buffered so secrets split across writes are masked as well,
+// a pending partial line is passed on by Flush.
+func (r *redactor) Writer(w io.Writer) *redactingWriter {
+	return &redactingWriter{redactor: r, w: w}
+}
+
+type redactingWriter struct {
+	mu       sync.Mutex
+	redactor *redactor
+	w        io.Writer
+	buf      []byte
+}
+
+func (w *redactingWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+
+	end := bytes.LastIndexByte(w.buf, '\n') + 1
+	if end == 0 && len(w.buf) > DefaultMaxLineLength {
+		// bound memory for output without newlines
+		end = w.redactor.safeEnd(w.buf)
+	}
+	if end == 0 {
+		return len(p), nil
+	}
+
+	_, err = io.WriteString(w.w, w.redactor.Redact(string(w.buf[:end])))
+	w.buf = append(w.buf[:0], w.buf[end:]...)
+	return len(p), err
+}
+
+// Pass on a pending partial line (if any) and flush the underlying writer
+// if it supports flushing
+func (w *redactingWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		if _, err := io.WriteString(w.w, w.redactor.Redact(string(w.buf))); err != nil {
+			return err
+		}
+		w.buf = w.buf[:0]
+	}
+
+	if f, ok := w.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Offset up to which buf can be redacted and passed on without knowing what follows.
+// The tail which might hold the beginning of a secret is kept, a secret crossing the
+// offset is passed on completely.
+func (r *redactor) safeEnd(buf []byte) int {
+	if len(r.variants) == 0 {
+		return len(buf)
+	}
+	// variants are sorted longest first
+	end := max(len(buf)-(len(r.variants[0])-1), 0)
+	for moved := true; moved; {
+		moved = false
+		for _, v := range r.variants {
+			from := max(end-len(v)+1, 0)
+			if i := bytes.Index(buf[from:min(end+len(v)-1, len(buf))], []byte(v)); i >= 0 && from+i < end {
+				end = from + i + len(v)
+				moved = true
+			}
+		}
+	}
+	return end
+}
+
+func redactPattern(p *regexp.Regexp, s string) string {
+	if p.NumSubexp() == 0 {
+		return p.ReplaceAllLiteralString(s, Redacted)
+	}
+
+	sb := strings.Builder{}
+	last := 0
+	for _, match := range p.FindAllStringSubmatchIndex(s, -1) {
+		for i := 2; i < len(match); i += 2 {
+			start, end := match[i], match[i+1]
+			if start < last || start < 0 {
+				continue
+			}
+			sb.WriteString(s[last:start])
+			sb.WriteString(Redacted)
+			last = end
+		}
+	}
+	sb.WriteString(s[last:])
+	return sb.String()
+}
+
+// The parts of the base64 encoding of secret which do not depend on surrounding
+// bytes, for each of the three possible alignments within a longer encoded string
+func base64Variants(enc *base64.Encoding, secret string) []string {
+	variants := []string{}
+	for offset := 0; offset < 3; offset++ {
+		encoded := enc.EncodeToString(append(make([]byte, offset), secret...))
+		// every character encodes 6 bits, only keep characters whose bits all stem from secret
+		start := (8*offset + 5) / 6
+		end := 8 * (offset + len(secret)) / 6
+		if end-start > 0 {
+			variants = append(variants, encoded[start:end])
+		}
+	}
+	return variants
+}
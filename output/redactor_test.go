@@ -0,0 +1,71 @@
+package output_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/dunv/utask/output"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactorRedact(t *testing.T) {
+	secret := "s3cr3t/t0ken+value"
+	r := output.NewRedactor([]string{secret, "s3cr3t"}, []*regexp.Regexp{
+		regexp.MustCompile(`password=(\S+)`),
+		regexp.MustCompile(`ghp_[A-Za-z0-9]+`),
+	})
+
+	tests := map[string]string{
+		"token " + secret: "token ***",
+		"short s3cr3t":    "short ***",
+		"std " + base64.StdEncoding.EncodeToString([]byte(secret)): "std ***",
+		"url " + base64.URLEncoding.EncodeToString([]byte(secret)): "url ***",
+		"query ?t=" + url.QueryEscape(secret):                      "query ?t=***",
+		"path /" + url.PathEscape(secret):                          "path /***",
+		"login password=hunter2 user=me":                           "login password=*** user=me",
+		"github ghp_abcDEF123":                                     "github ***",
+	}
+	for input, expected := range tests {
+		require.Equal(t, expected, r.Redact(input), input)
+	}
+
+	// secret embedded in a longer base64 encoded string (e.g. basic auth)
+	for _, prefix := range []string{"u:", "us:", "use:"} {
+		encoded := base64.StdEncoding.EncodeToString([]byte(prefix + secret + "!"))
+		require.NotContains(t, r.Redact(encoded), encoded[len(prefix)*4/3+2:len(encoded)-4])
+		require.Contains(t, r.Redact(encoded), output.Redacted)
+	}
+}
+
+func TestRedactorWriterSplitWrites(t *testing.T) {
+	r := output.NewRedactor([]string{"secret"}, nil)
+	buf := &bytes.Buffer{}
+	w := r.Writer(buf)
+	for _, chunk := range []string{"the se", "cr", "et is\nsecr", "et"} {
+		_, err := w.Write([]byte(chunk))
+		require.NoError(t, err)
+	}
+	require.Equal(t, "the *** is\n", buf.String())
+	require.NoError(t, w.Flush())
+	require.Equal(t, "the *** is\n***", buf.String())
+}
+
+func TestRedactorWriterLongLine(t *testing.T) {
+	r := output.NewRedactor([]string{"secret"}, nil)
+	buf := &bytes.Buffer{}
+	w := r.Writer(buf)
+
+	// a line without newline is passed on once it exceeds the maximum length,
+	// the secret crosses that boundary
+	long := strings.Repeat("x", output.DefaultMaxLineLength-1)
+	for _, chunk := range []string{long, "secr", "et-sec", "ret"} {
+		_, err := w.Write([]byte(chunk))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Flush())
+	require.Equal(t, long+"***-***", buf.String())
+}
@@ -0,0 +1,395 @@
+package output
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Default number of lines the cursor can move up (the height of the emulated screen)
+const DefaultTerminalHeight = 24
+
+// Default column the cursor can at most be moved to (the width of the emulated screen)
+const DefaultTerminalWidth = 1024
+
+// Emulates a terminal to render the final state of output using carriage returns
+// and ANSI escape sequences (progress bars of pip, docker pull, curl, ...)
+//   - interprets '\r', '\b', '\t', cursor movement, erase line/screen and SGR (colors, bold, ...)
+//   - a line is settled once it scrolled out of the screen (see WithTerminalHeight)
+//     or the terminal is closed, only settled lines are written
+//   - settled lines are written as plain text to w and optionally as HTML (see WithTerminalHTML)
+//   - safe for concurrent use
+type terminal struct {
+	mu     sync.Mutex
+	opts   terminalOptions
+	w      io.Writer
+	rows   [][]cell
+	row    int
+	col    int
+	saved  [2]int
+	style  style
+	parser parserState
+	params []byte
+	utf8   []byte
+	err    error
+	closed bool
+}
+
+type cell struct {
+	r     rune
+	style style
+}
+
+// Create a terminal writing settled lines to w
+func NewTerminal(w io.Writer, opts ...TerminalOption) *terminal {
+	mergedOpts := terminalOptions{
+		height: DefaultTerminalHeight,
+		width:  DefaultTerminalWidth,
+	}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+
+	t := &terminal{
+		opts:  mergedOpts,
+		w:     w,
+		rows:  [][]cell{{}},
+		style: defaultStyle,
+	}
+	if t.opts.html != nil {
+		t.writeHTML(htmlHeader)
+	}
+	return t
+}
+
+type parserState int
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateCSI
+	stateOSC
+	stateOSCEscape
+	// the byte after "ESC (" (and ")", "*", "+") designates a character set
+	stateCharset
+)
+
+func (t *terminal) Write(p []byte) (n int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return 0, ErrClosed
+	}
+
+	n = len(p)
+
+	// complete a rune split across writes
+	if len(t.utf8) > 0 {
+		p = append(t.utf8, p...)
+		t.utf8 = nil
+	}
+
+	for i := 0; i < len(p); {
+		b := p[i]
+		if t.parser != stateGround || b < utf8.RuneSelf {
+			t.handleByte(b)
+			i++
+			continue
+		}
+
+		if !utf8.FullRune(p[i:]) {
+			t.utf8 = append([]byte{}, p[i:]...)
+			break
+		}
+		r, size := utf8.DecodeRune(p[i:])
+		t.put(r)
+		i += size
+	}
+
+	t.settle(false)
+	return n, t.err
+}
+
+// Write all remaining lines (they are settled now) and finish the HTML rendering
+func (t *terminal) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return ErrClosed
+	}
+	t.closed = true
+
+	t.settle(true)
+	if t.opts.html != nil {
+		t.writeHTML(htmlFooter)
+	}
+	return t.err
+}
+
+func (t *terminal) handleByte(b byte) {
+	switch t.parser {
+	case stateEscape:
+		t.parser = stateGround
+		switch b {
+		case '[':
+			t.parser = stateCSI
+			t.params = t.params[:0]
+		case ']':
+			t.parser = stateOSC
+		case '(', ')', '*', '+':
+			t.parser = stateCharset
+		case '7':
+			t.saved = [2]int{t.row, t.col}
+		case '8':
+			t.row, t.col = t.saved[0], t.saved[1]
+			t.clampRow()
+			t.clampCol()
+		}
+	case stateCSI:
+		if b >= 0x40 && b <= 0x7e {
+			t.parser = stateGround
+			t.csi(b)
+		} else {
+			t.params = append(t.params, b)
+		}
+	case stateOSC:
+		switch b {
+		case 0x07:
+			t.parser = stateGround
+		case 0x1b:
+			t.parser = stateOSCEscape
+		}
+	case stateOSCEscape:
+		// ESC \ terminates the OSC sequence
+		t.parser = stateGround
+	case stateCharset:
+		// character sets are not emulated
+		t.parser = stateGround
+	default:
+		switch b {
+		case 0x1b:
+			t.parser = stateEscape
+		case '\n':
+			t.row++
+			t.col = 0
+			if t.row == len(t.rows) {
+				t.rows = append(t.rows, []cell{})
+			}
+		case '\r':
+			t.col = 0
+		case '\b':
+			if t.col > 0 {
+				t.col--
+			}
+		case '\t':
+			t.col = (t.col/8 + 1) * 8
+			t.clampCol()
+		default:
+			if b >= 0x20 && b != 0x7f {
+				t.put(rune(b))
+			}
+		}
+	}
+}
+
+// write a rune at the cursor position
+func (t *terminal) put(r rune) {
+	line := t.rows[t.row]
+	for len(line) <= t.col {
+		line = append(line, cell{r: ' ', style: defaultStyle})
+	}
+	line[t.col] = cell{r: r, style: t.style}
+	t.rows[t.row] = line
+	t.col++
+}
+
+// handle a control sequence ("ESC [" params final)
+func (t *terminal) csi(final byte) {
+	// private sequences (e.g. "?25l" hide cursor) are ignored
+	if len(t.params) > 0 && (t.params[0] == '?' || t.params[0] == '>' || t.params[0] == '=') {
+		return
+	}
+
+	params := parseParams(string(t.params))
+	n := param(params, 0, 1)
+	if n == 0 {
+		n = 1
+	}
+
+	switch final {
+	case 'A':
+		t.row -= n
+		t.clampRow()
+	case 'B':
+		t.row += n
+		t.clampRow()
+	case 'C':
+		t.col += n
+		t.clampCol()
+	case 'D':
+		t.col -= n
+		t.clampCol()
+	case 'E':
+		t.row += n
+		t.col = 0
+		t.clampRow()
+	case 'F':
+		t.row -= n
+		t.col = 0
+		t.clampRow()
+	case 'G':
+		t.col = n - 1
+		t.clampCol()
+	case 'H', 'f':
+		// rows are relative to the top of the screen
+		top := max(len(t.rows)-t.opts.height, 0)
+		t.row = top + max(param(params, 0, 1), 1) - 1
+		t.col = max(param(params, 1, 1), 1) - 1
+		t.clampRow()
+		t.clampCol()
+	case 'K':
+		t.eraseLine(param(params, 0, 0))
+	case 'J':
+		t.eraseScreen(param(params, 0, 0))
+	case 'm':
+		t.style = t.style.apply(params)
+	case 's':
+		t.saved = [2]int{t.row, t.col}
+	case 'u':
+		t.row, t.col = t.saved[0], t.saved[1]
+		t.clampRow()
+		t.clampCol()
+	}
+}
+
+func (t *terminal) eraseLine(mode int) {
+	line := t.rows[t.row]
+	switch mode {
+	case 0:
+		if t.col < len(line) {
+			t.rows[t.row] = line[:t.col]
+		}
+	case 1:
+		for i := 0; i <= t.col && i < len(line); i++ {
+			line[i] = cell{r: ' ', style: defaultStyle}
+		}
+	case 2:
+		t.rows[t.row] = line[:0]
+	}
+}
+
+func (t *terminal) eraseScreen(mode int) {
+	switch mode {
+	case 0:
+		t.eraseLine(0)
+		t.rows = t.rows[:t.row+1]
+	case 1:
+		top := max(len(t.rows)-t.opts.height, 0)
+		for i := top; i < t.row; i++ {
+			t.rows[i] = t.rows[i][:0]
+		}
+		t.eraseLine(1)
+	case 2, 3:
+		top := max(len(t.rows)-t.opts.height, 0)
+		for i := top; i < len(t.rows); i++ {
+			t.rows[i] = t.rows[i][:0]
+		}
+	}
+}
+
+// the cursor can only be moved within existing lines
+func (t *terminal) clampRow() {
+	top := max(len(t.rows)-t.opts.height, 0)
+	t.row = min(max(t.row, top), len(t.rows)-1)
+}
+
+// the cursor can not be moved beyond the width of the screen (writing text can)
+func (t *terminal) clampCol() {
+	t.col = min(max(t.col, 0), t.opts.width-1)
+}
+
+// write lines which scrolled out of the screen (or all lines if final)
+func (t *terminal) settle(final bool) {
+	settled := max(len(t.rows)-t.opts.height, 0)
+	if final {
+		settled = len(t.rows)
+		// the line after a trailing newline is not part of the output
+		if len(t.rows[len(t.rows)-1]) == 0 {
+			settled--
+		}
+	}
+	if settled <= 0 {
+		return
+	}
+
+	for _, line := range t.rows[:settled] {
+		t.writeLine(line)
+	}
+	t.rows = append(t.rows[:0], t.rows[settled:]...)
+	if len(t.rows) == 0 {
+		t.rows = [][]cell{{}}
+	}
+	t.row = max(t.row-settled, 0)
+}
+
+func (t *terminal) writeLine(line []cell) {
+	sb := strings.Builder{}
+	for _, c := range line {
+		sb.WriteRune(c.r)
+	}
+	text := strings.TrimRight(sb.String(), " ")
+	if _, err := io.WriteString(t.w, text+"\n"); err != nil && t.err == nil {
+		t.err = err
+	}
+
+	if t.opts.html != nil {
+		t.writeHTML(renderHTMLLine(trimCells(line)))
+	}
+}
+
+func (t *terminal) writeHTML(s string) {
+	if _, err := io.WriteString(t.opts.html, s); err != nil && t.err == nil {
+		t.err = err
+	}
+}
+
+// remove trailing unstyled blanks
+func trimCells(line []cell) []cell {
+	end := len(line)
+	for end > 0 && line[end-1].r == ' ' && line[end-1].style == defaultStyle {
+		end--
+	}
+	return line[:end]
+}
+
+const maxParam = 1 << 16
+
+func parseParams(s string) []int {
+	if s == "" {
+		return nil
+	}
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == ':' })
+	params := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		// out of range values are returned as the maximum int
+		if (err != nil && !errors.Is(err, strconv.ErrRange)) || n < 0 {
+			n = 0
+		}
+		// large enough for any screen, small enough for cursor arithmetic not to overflow
+		params = append(params, min(n, maxParam))
+	}
+	return params
+}
+
+func param(params []int, i int, def int) int {
+	if i < len(params) {
+		return params[i]
+	}
+	return def
+}
@@ -0,0 +1,55 @@
+package output
+
+import "io"
+
+type terminalOptions struct {
+	height int
+	width  int
+	html   io.Writer
+}
+
+// Options for a terminal
+type TerminalOption interface {
+	apply(*terminalOptions)
+}
+
+type funcTerminalOption struct {
+	f func(*terminalOptions)
+}
+
+// nolint:unused
+func (fto *funcTerminalOption) apply(o *terminalOptions) {
+	fto.f(o)
+}
+
+func newFuncTerminalOption(f func(*terminalOptions)) *funcTerminalOption {
+	return &funcTerminalOption{f: f}
+}
+
+// Height of the emulated screen, lines are settled once they scrolled out of it
+// (default: DefaultTerminalHeight)
+func WithTerminalHeight(height int) TerminalOption {
+	return newFuncTerminalOption(func(o *terminalOptions) {
+		if height > 0 {
+			o.height = height
+		}
+	})
+}
+
+// Width of the emulated screen, cursor movements beyond it are clamped
+// (default: DefaultTerminalWidth)
+func WithTerminalWidth(width int) TerminalOption {
+	return newFuncTerminalOption(func(o *terminalOptions) {
+		if width > 0 {
+			o.width = width
+		}
+	})
+}
+
+// Additionally write settled lines as HTML (with colors) to w.
+// The rendering is a <pre class="utask-terminal"> element, which is completed by Close.
+func WithTerminalHTML(w io.Writer) TerminalOption {
+	return newFuncTerminalOption(func(o *terminalOptions) {
+		o.html = w
+	})
+}
@@ -0,0 +1,182 @@
+package output
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// color of a cell: colorDefault, 0-255 (palette) or colorRGB|0xRRGGBB
+type color int32
+
+const (
+	colorDefault color = -1
+	colorRGB     color = 1 << 24
+)
+
+// attributes of a cell as set by SGR sequences
+type style struct {
+	fg        color
+	bg        color
+	bold      bool
+	faint     bool
+	italic    bool
+	underline bool
+	inverse   bool
+}
+
+var defaultStyle = style{fg: colorDefault, bg: colorDefault}
+
+// apply SGR parameters ("ESC [ ... m")
+func (s style) apply(params []int) style {
+	if len(params) == 0 {
+		return defaultStyle
+	}
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		switch {
+		case p == 0:
+			s = defaultStyle
+		case p == 1:
+			s.bold = true
+		case p == 2:
+			s.faint = true
+		case p == 3:
+			s.italic = true
+		case p == 4:
+			s.underline = true
+		case p == 7:
+			s.inverse = true
+		case p == 22:
+			s.bold, s.faint = false, false
+		case p == 23:
+			s.italic = false
+		case p == 24:
+			s.underline = false
+		case p == 27:
+			s.inverse = false
+		case p >= 30 && p <= 37:
+			s.fg = color(p - 30)
+		case p == 38, p == 48:
+			c, consumed := extendedColor(params[i+1:])
+			i += consumed
+			if p == 38 {
+				s.fg = c
+			} else {
+				s.bg = c
+			}
+		case p == 39:
+			s.fg = colorDefault
+		case p >= 40 && p <= 47:
+			s.bg = color(p - 40)
+		case p == 49:
+			s.bg = colorDefault
+		case p >= 90 && p <= 97:
+			s.fg = color(p - 90 + 8)
+		case p >= 100 && p <= 107:
+			s.bg = color(p - 100 + 8)
+		}
+	}
+	return s
+}
+
+// parse "5;n" (palette) or "2;r;g;b" (true color), returns the number of parameters consumed
+func extendedColor(params []int) (color, int) {
+	if len(params) >= 2 && params[0] == 5 {
+		return color(params[1] & 0xff), 2
+	}
+	if len(params) >= 4 && params[0] == 2 {
+		return colorRGB | color((params[1]&0xff)<<16|(params[2]&0xff)<<8|params[3]&0xff), 4
+	}
+	return colorDefault, len(params)
+}
+
+// standard xterm colors 0-15
+var basicColors = [16]int{
+	0x000000, 0xcd0000, 0x00cd00, 0xcdcd00, 0x0000ee, 0xcd00cd, 0x00cdcd, 0xe5e5e5,
+	0x7f7f7f, 0xff0000, 0x00ff00, 0xffff00, 0x5c5cff, 0xff00ff, 0x00ffff, 0xffffff,
+}
+
+// hex representation of a color (e.g. #ff0000)
+func (c color) hex() string {
+	var rgb int
+	switch {
+	case c&colorRGB != 0:
+		rgb = int(c &^ colorRGB)
+	case c < 16:
+		rgb = basicColors[c]
+	case c < 232:
+		// 6x6x6 color cube
+		levels := [6]int{0, 95, 135, 175, 215, 255}
+		i := int(c) - 16
+		rgb = levels[i/36]<<16 | levels[i/6%6]<<8 | levels[i%6]
+	default:
+		// grayscale ramp
+		gray := 8 + (int(c)-232)*10
+		rgb = gray<<16 | gray<<8 | gray
+	}
+	return fmt.Sprintf("#%06x", rgb)
+}
+
+// inline css for a style
+func (s style) css() string {
+	fg, bg := s.fg, s.bg
+	if s.inverse {
+		fg, bg = bg, fg
+		if fg == colorDefault {
+			fg = 0
+		}
+		if bg == colorDefault {
+			bg = 7
+		}
+	}
+
+	css := []string{}
+	if fg != colorDefault {
+		css = append(css, "color:"+fg.hex())
+	}
+	if bg != colorDefault {
+		css = append(css, "background-color:"+bg.hex())
+	}
+	if s.bold {
+		css = append(css, "font-weight:bold")
+	}
+	if s.faint {
+		css = append(css, "opacity:0.7")
+	}
+	if s.italic {
+		css = append(css, "font-style:italic")
+	}
+	if s.underline {
+		css = append(css, "text-decoration:underline")
+	}
+	return strings.Join(css, ";")
+}
+
+const (
+	htmlHeader = "<pre class=\"utask-terminal\">\n"
+	htmlFooter = "</pre>\n"
+)
+
+// render a line as html, consecutive cells with the same style share a span
+func renderHTMLLine(line []cell) string {
+	sb := strings.Builder{}
+	for start := 0; start < len(line); {
+		end := start
+		text := strings.Builder{}
+		for end < len(line) && line[end].style == line[start].style {
+			text.WriteRune(line[end].r)
+			end++
+		}
+
+		escaped := html.EscapeString(text.String())
+		if css := line[start].style.css(); css != "" {
+			sb.WriteString(`<span style="` + css + `">` + escaped + `</span>`)
+		} else {
+			sb.WriteString(escaped)
+		}
+		start = end
+	}
+	sb.WriteByte('\n')
+	return sb.String()
+}
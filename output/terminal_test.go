@@ -0,0 +1,109 @@
+package output_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dunv/utask/output"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerminalCarriageReturn(t *testing.T) {
+	buf := &bytes.Buffer{}
+	term := output.NewTerminal(buf)
+	for _, chunk := range []string{"Downloading  10%", "\rDownloading  50%", "\rDownloading 100%\n", "done\r\n"} {
+		_, err := term.Write([]byte(chunk))
+		require.NoError(t, err)
+	}
+	require.NoError(t, term.Close())
+	require.Equal(t, "Downloading 100%\ndone\n", buf.String())
+}
+
+func TestTerminalCursorMovement(t *testing.T) {
+	buf := &bytes.Buffer{}
+	term := output.NewTerminal(buf)
+	// docker pull style: one line per layer, updated in place
+	_, _ = term.Write([]byte("layer1: waiting\nlayer2: waiting\n"))
+	_, _ = term.Write([]byte("\x1b[2A\x1b[2Klayer1: done\n\x1b[1B"))
+	_, _ = term.Write([]byte("\x1b[1A\r\x1b[Klayer2: done\n"))
+	_, _ = term.Write([]byte("partial line\b\b\b\bLINE"))
+	require.NoError(t, term.Close())
+	require.Equal(t, "layer1: done\nlayer2: done\npartial LINE\n", buf.String())
+}
+
+func TestTerminalClampsColumn(t *testing.T) {
+	buf := &bytes.Buffer{}
+	term := output.NewTerminal(buf, output.WithTerminalWidth(10))
+	_, _ = term.Write([]byte("a\x1b[999999999Cb\n\x1b[999999999Gc\x1b[1;999999999Hd\n"))
+	require.NoError(t, term.Close())
+	require.Equal(t, "a        d\n         c\n", buf.String())
+}
+
+func TestTerminalInvalidCursorMovement(t *testing.T) {
+	for input, expected := range map[string]string{
+		"abc\x1b[0Gx":                    "xbc\n",
+		"abc\x1b[-1Gx":                   "xbc\n",
+		"abc\x1b[-5Cx":                   "abc x\n",
+		"abc\x1b[99999999999999999999Cx": "abc      x\n",
+		"abc\x1b[9223372036854775807Cx":  "abc      x\n",
+		"abc\x1b[9223372036854775807Dx":  "xbc\n",
+	} {
+		buf := &bytes.Buffer{}
+		term := output.NewTerminal(buf, output.WithTerminalWidth(10))
+		_, err := term.Write([]byte(input))
+		require.NoError(t, err)
+		require.NoError(t, term.Close())
+		require.Equal(t, expected, buf.String(), "%q", input)
+	}
+}
+
+func TestTerminalCharsetDesignation(t *testing.T) {
+	buf := &bytes.Buffer{}
+	term := output.NewTerminal(buf)
+	// tput sgr0 and line drawing
+	_, _ = term.Write([]byte("\x1b(B\x1b[mplain \x1b)0\x1b(0lqk\x1b(B\n"))
+	require.NoError(t, term.Close())
+	require.Equal(t, "plain lqk\n", buf.String())
+}
+
+func TestTerminalSettledLines(t *testing.T) {
+	buf := &bytes.Buffer{}
+	term := output.NewTerminal(buf, output.WithTerminalHeight(2))
+	_, _ = term.Write([]byte("1\n"))
+	require.Equal(t, "", buf.String())
+	// the cursor is on the third line now, the first one scrolled out of the screen
+	_, _ = term.Write([]byte("2\n"))
+	require.Equal(t, "1\n", buf.String())
+	_, _ = term.Write([]byte("3\n"))
+	require.Equal(t, "1\n2\n", buf.String())
+
+	// the cursor cannot reach settled lines, "3" is overwritten
+	_, _ = term.Write([]byte("\x1b[10Aup\n"))
+	require.NoError(t, term.Close())
+	require.Equal(t, "1\n2\nup\n", buf.String())
+}
+
+func TestTerminalStripsEscapes(t *testing.T) {
+	buf := &bytes.Buffer{}
+	term := output.NewTerminal(buf)
+	_, _ = term.Write([]byte("\x1b]0;title\x07\x1b[?25l\x1b[1;31mred\x1b[0m \xe2\x9c"))
+	_, _ = term.Write([]byte("\x93\x1b[?25h\n"))
+	require.NoError(t, term.Close())
+	require.Equal(t, "red ✓\n", buf.String())
+}
+
+func TestTerminalHTML(t *testing.T) {
+	buf := &bytes.Buffer{}
+	html := &bytes.Buffer{}
+	term := output.NewTerminal(buf, output.WithTerminalHTML(html))
+	_, _ = term.Write([]byte("\x1b[1;31mfail\x1b[0m <a>\n\x1b[38;5;208morange\x1b[48;2;0;0;255m on blue\x1b[m\n"))
+	require.NoError(t, term.Close())
+	require.Equal(t, strings.Join([]string{
+		`<pre class="utask-terminal">`,
+		`<span style="color:#cd0000;font-weight:bold">fail</span> &lt;a&gt;`,
+		`<span style="color:#ff8700">orange</span><span style="color:#ff8700;background-color:#0000ff"> on blue</span>`,
+		`</pre>`,
+	}, "\n")+"\n", html.String())
+	require.Equal(t, "fail <a>\norange on blue\n", buf.String())
+}
@@ -11,25 +11,26 @@ import (
 type Output interface {
 	io.Writer
 	Lines() []string
+	String() string
 }
 
-type output struct {
+type testOutput struct {
 	buf *bytes.Buffer
 }
 
 // create helper for collecting and checking output
 // in tests
-func NewOutput() *output {
-	return &output{
+func NewOutput() *testOutput {
+	return &testOutput{
 		buf: bytes.NewBuffer([]byte{}),
 	}
 }
 
-func (o *output) Write(p []byte) (n int, err error) {
+func (o *testOutput) Write(p []byte) (n int, err error) {
 	return o.buf.Write(p)
 }
 
-func (o *output) Lines() []string {
+func (o *testOutput) Lines() []string {
 	output := []string{}
 	sc := bufio.NewScanner(o.buf)
 	for sc.Scan() {
@@ -40,3 +41,8 @@ func (o *output) Lines() []string {
 	}
 	return output
 }
+
+// everything written so far (does not consume the output)
+func (o *testOutput) String() string {
+	return o.buf.String()
+}
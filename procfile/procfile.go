@@ -0,0 +1,83 @@
+package procfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A process type declared in a Procfile
+type Process struct {
+	Name    string
+	Command string
+}
+
+var processName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Parse a Procfile
+//   - one process type per line: `<name>: <command>`
+//   - names consist of letters, digits, '_' and '-'
+//   - empty lines and lines starting with '#' are ignored
+func Parse(r io.Reader) ([]Process, error) {
+	processes := []Process{}
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, command, ok := strings.Cut(line, ":")
+		name, command = strings.TrimSpace(name), strings.TrimSpace(command)
+		if !ok || !processName.MatchString(name) {
+			return nil, fmt.Errorf("procfile: line %d: expected '<name>: <command>', got %q", lineNumber, line)
+		}
+		if command == "" {
+			return nil, fmt.Errorf("procfile: line %d: no command given for %q", lineNumber, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("procfile: line %d: duplicate process type %q", lineNumber, name)
+		}
+		seen[name] = true
+		processes = append(processes, Process{Name: name, Command: command})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return processes, nil
+}
+
+// Parse the Procfile at path
+func ReadFile(path string) ([]Process, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse a formation (number of instances per process type), e.g. "web=2,worker=1".
+// The name "all" sets the default for all process types not given explicitly.
+func ParseFormation(spec string) (map[string]int, error) {
+	formation := map[string]int{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, count, ok := strings.Cut(part, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		name = strings.TrimSpace(name)
+		if !ok || err != nil || n < 0 || !processName.MatchString(name) {
+			return nil, fmt.Errorf("procfile: invalid formation %q, expected '<name>=<count>'", part)
+		}
+		formation[name] = n
+	}
+	return formation, nil
+}
@@ -0,0 +1,76 @@
+package procfile_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dunv/utask/procfile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	processes, err := procfile.Parse(strings.NewReader(`
+# development processes
+web: ./server --port $PORT
+worker:   bundle exec sidekiq -c 5
+
+release-task: ./migrate: now
+`))
+	require.NoError(t, err)
+	require.Equal(t, []procfile.Process{
+		{Name: "web", Command: "./server --port $PORT"},
+		{Name: "worker", Command: "bundle exec sidekiq -c 5"},
+		{Name: "release-task", Command: "./migrate: now"},
+	}, processes)
+}
+
+func TestParseErrors(t *testing.T) {
+	for input, expected := range map[string]string{
+		"web ./server":         "line 1: expected '<name>: <command>'",
+		"web: ./a\nweb: ./b":   `line 2: duplicate process type "web"`,
+		"\nweb:":               `line 2: no command given for "web"`,
+		"web server: ./server": "line 1: expected '<name>: <command>'",
+	} {
+		_, err := procfile.Parse(strings.NewReader(input))
+		require.ErrorContains(t, err, expected)
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	env, err := procfile.ParseEnv(strings.NewReader(`
+# comment
+PLAIN=value
+export EXPORTED=yes
+SPACED = trimmed value # inline comment
+SINGLE='literal \n $HOME # not a comment'
+DOUBLE="line1\nline2 \"quoted\""
+EMPTY=
+HASH=abc#def
+`))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"PLAIN=value",
+		"EXPORTED=yes",
+		"SPACED=trimmed value",
+		`SINGLE=literal \n $HOME # not a comment`,
+		"DOUBLE=line1\nline2 \"quoted\"",
+		"EMPTY=",
+		"HASH=abc#def",
+	}, env)
+
+	_, err = procfile.ParseEnv(strings.NewReader("A=1\nB='open"))
+	require.ErrorContains(t, err, "env line 2: unterminated quote")
+	_, err = procfile.ParseEnv(strings.NewReader("1A=1"))
+	require.ErrorContains(t, err, "env line 1")
+}
+
+func TestParseFormation(t *testing.T) {
+	formation, err := procfile.ParseFormation("all=1, web=2,worker=0")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"all": 1, "web": 2, "worker": 0}, formation)
+
+	for _, spec := range []string{"web", "web=x", "web=-1", "web=2x"} {
+		_, err := procfile.ParseFormation(spec)
+		require.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,156 @@
+package procfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dunv/utask"
+	"github.com/dunv/utask/output"
+)
+
+// Name of the multiplexer writer used for messages of the runner itself
+const SystemName = "system"
+
+// Runs the processes of a Procfile (like `foreman start`)
+//   - every instance runs as a shell task (`sh -c <command>`) in its own process group
+//   - output of all instances is multiplexed, prefixed with "<name>.<instance>"
+//   - once any instance ended, all others are stopped
+type runner struct {
+	processes []Process
+	opts      runnerOptions
+}
+
+// Create a runner for processes. Fails if the formation references unknown process types.
+func New(processes []Process, opts ...Option) (*runner, error) {
+	mergedOpts := runnerOptions{
+		output:      os.Stdout,
+		basePort:    5000,
+		stopTimeout: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+
+	known := map[string]bool{"all": true}
+	for _, p := range processes {
+		known[p.Name] = true
+	}
+	for name := range mergedOpts.formation {
+		if !known[name] {
+			return nil, fmt.Errorf("procfile: formation references unknown process type %q", name)
+		}
+	}
+
+	return &runner{processes: processes, opts: mergedOpts}, nil
+}
+
+// Number of instances of the process type
+func (r *runner) instances(name string) int {
+	if n, ok := r.opts.formation[name]; ok {
+		return n
+	}
+	if n, ok := r.opts.formation["all"]; ok {
+		return n
+	}
+	return 1
+}
+
+type instance struct {
+	name string
+	task utask.Task
+}
+
+// Start all instances and wait until the first one ends (or ctx is done), then stop
+// all others and wait for them. Returns the error of the instance that ended first
+// (nil if it exited successfully) or ctx.Err().
+func (r *runner) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	m := output.NewMultiplexer(r.opts.output, r.opts.multiplexerOpts...)
+	system := m.Writer(SystemName)
+	defer system.Flush()
+
+	instances := []instance{}
+	for i, p := range r.processes {
+		for n := 1; n <= r.instances(p.Name); n++ {
+			name := fmt.Sprintf("%s.%d", p.Name, n)
+			env := append(os.Environ(), r.opts.env...)
+			if r.opts.basePort > 0 {
+				env = append(env, "PORT="+strconv.Itoa(r.opts.basePort+100*i+n-1))
+			}
+
+			w := m.Writer(name)
+			task, err := utask.NewShellTask(
+				utask.WithShellContext(ctx),
+				utask.WithShellName(name),
+				utask.WithShellCommand("sh", "-c", p.Command),
+				utask.WithShellEnvironment(env),
+				utask.WithShellWorkingDir(r.opts.workingDir),
+				utask.WithShellWaitDelay(r.opts.stopTimeout),
+				utask.WithShellCombinedOutput(w),
+				utask.WithShellLifecycleHook(func(e utask.LifecycleEvent) {
+					switch e.Phase {
+					case utask.LifecycleStart:
+						fmt.Fprintf(system, "%s started with pid %d\n", e.Name, e.Pid)
+					case utask.LifecycleSuccess:
+						fmt.Fprintf(system, "%s exited\n", e.Name)
+					case utask.LifecycleFailure:
+						fmt.Fprintf(system, "%s exited: %s\n", e.Name, e.Err)
+					}
+				}),
+			)
+			if err != nil {
+				return err
+			}
+			instances = append(instances, instance{name: name, task: task})
+		}
+	}
+	if len(instances) == 0 {
+		return errors.New("procfile: no processes to run")
+	}
+
+	type exit struct {
+		name string
+		err  error
+	}
+	exits := make(chan exit, len(instances))
+	wg := sync.WaitGroup{}
+	for _, inst := range instances {
+		if err := inst.task.Start(); err != nil {
+			cancel()
+			wg.Wait()
+			return fmt.Errorf("procfile: starting %s: %w", inst.name, err)
+		}
+		wg.Add(1)
+		go func(inst instance) {
+			defer wg.Done()
+			exits <- exit{name: inst.name, err: inst.task.Wait()}
+		}(inst)
+	}
+
+	var first exit
+	select {
+	case first = <-exits:
+		if ctx.Err() == nil {
+			fmt.Fprintf(system, "%s ended, stopping all processes\n", first.name)
+		}
+	case <-ctx.Done():
+	}
+	stopped := ctx.Err() != nil
+	cancel()
+	wg.Wait()
+
+	if stopped && first.name == "" {
+		return ctx.Err()
+	}
+	if first.err != nil {
+		return fmt.Errorf("procfile: %s: %w", first.name, first.err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package procfile
+
+import (
+	"io"
+	"time"
+
+	"github.com/dunv/utask/output"
+)
+
+type runnerOptions struct {
+	formation       map[string]int
+	env             []string
+	workingDir      string
+	output          io.Writer
+	multiplexerOpts []output.MultiplexerOption
+	basePort        int
+	stopTimeout     time.Duration
+}
+
+// Options for a runner
+type Option interface {
+	apply(*runnerOptions)
+}
+
+type funcOption struct {
+	f func(*runnerOptions)
+}
+
+// nolint:unused
+func (fo *funcOption) apply(o *runnerOptions) {
+	fo.f(o)
+}
+
+func newFuncOption(f func(*runnerOptions)) *funcOption {
+	return &funcOption{f: f}
+}
+
+// Number of instances per process type, e.g. from ParseFormation (default: 1 of each)
+func WithFormation(formation map[string]int) Option {
+	return newFuncOption(func(o *runnerOptions) {
+		o.formation = formation
+	})
+}
+
+// Environment variables (KEY=VALUE, e.g. from ReadEnvFile) added to the
+// environment of the runner for every process
+func WithEnv(env []string) Option {
+	return newFuncOption(func(o *runnerOptions) {
+		o.env = append(o.env, env...)
+	})
+}
+
+// Working directory of all processes (default: the current directory)
+func WithWorkingDir(dir string) Option {
+	return newFuncOption(func(o *runnerOptions) {
+		o.workingDir = dir
+	})
+}
+
+// Write the multiplexed output to w (default: os.Stdout)
+func WithOutput(w io.Writer, opts ...output.MultiplexerOption) Option {
+	return newFuncOption(func(o *runnerOptions) {
+		o.output = w
+		o.multiplexerOpts = opts
+	})
+}
+
+// Set PORT for every instance to port + 100 * <index of process type> + <instance - 1>
+// (default: 5000, 0 does not set PORT)
+func WithBasePort(port int) Option {
+	return newFuncOption(func(o *runnerOptions) {
+		o.basePort = port
+	})
+}
+
+// Time processes get to exit after SIGTERM before they are killed (default: 5s)
+func WithStopTimeout(d time.Duration) Option {
+	return newFuncOption(func(o *runnerOptions) {
+		o.stopTimeout = d
+	})
+}
@@ -0,0 +1,60 @@
+package procfile_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dunv/utask/procfile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunnerStopsAllOnExit(t *testing.T) {
+	buf := bytes.Buffer{}
+	r, err := procfile.New([]procfile.Process{
+		{Name: "web", Command: "echo web on $PORT $GREETING; sleep 10"},
+		{Name: "worker", Command: "sleep 0.2; echo done; exit 3"},
+	},
+		procfile.WithFormation(map[string]int{"web": 2}),
+		procfile.WithEnv([]string{"GREETING=hello"}),
+		procfile.WithOutput(&buf),
+	)
+	require.NoError(t, err)
+
+	start := time.Now()
+	err = r.Run(context.Background())
+	require.EqualError(t, err, "procfile: worker.1: exit status 3")
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	out := buf.String()
+	require.Contains(t, out, "web.1    | web on 5000 hello\n")
+	require.Contains(t, out, "web.2    | web on 5001 hello\n")
+	require.Contains(t, out, "worker.1 | done\n")
+	require.Contains(t, out, "system   | worker.1 ended, stopping all processes\n")
+	require.Contains(t, out, "system   | web.1 exited: signal: terminated\n")
+}
+
+func TestRunnerCanceled(t *testing.T) {
+	buf := bytes.Buffer{}
+	r, err := procfile.New([]procfile.Process{{Name: "web", Command: "sleep 10"}}, procfile.WithOutput(&buf))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, r.Run(ctx), context.DeadlineExceeded)
+	require.True(t, strings.HasPrefix(buf.String(), "system | web.1 started with pid "))
+}
+
+func TestRunnerScaledToZero(t *testing.T) {
+	r, err := procfile.New([]procfile.Process{
+		{Name: "web", Command: "true"},
+		{Name: "worker", Command: "sleep 10"},
+	}, procfile.WithFormation(map[string]int{"all": 0, "web": 1}), procfile.WithOutput(&bytes.Buffer{}))
+	require.NoError(t, err)
+	require.NoError(t, r.Run(context.Background()))
+
+	_, err = procfile.New([]procfile.Process{{Name: "web", Command: "true"}}, procfile.WithFormation(map[string]int{"db": 1}))
+	require.ErrorContains(t, err, `unknown process type "db"`)
+}
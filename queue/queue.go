@@ -0,0 +1,377 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dunv/utask/output"
+)
+
+var (
+	// Returned by Lease if no job is pending
+	ErrEmpty = errors.New("queue: no pending jobs")
+	// Returned for jobs which do not exist (anymore) or are not leased by the caller
+	// (i.e. the lease id does not match, see Job.LeaseID)
+	ErrUnknownJob = errors.New("queue: unknown job")
+	// Returned by all methods after Close
+	ErrClosed = errors.New("queue: closed")
+)
+
+// State of a job
+type State string
+
+const (
+	StatePending State = "pending"
+	StateLeased  State = "leased"
+	// Attempts exhausted or failed by its recovery policy, kept until removed
+	StateFailed State = "failed"
+)
+
+// What happens to a job that was leased when the queue's process ended without
+// acknowledging it (e.g. a crash)
+type RecoveryPolicy string
+
+const (
+	// Run the job again (default)
+	RecoverRequeue RecoveryPolicy = "requeue"
+	// Mark the job as failed
+	RecoverFail RecoveryPolicy = "fail"
+	// Remove the job
+	RecoverDrop RecoveryPolicy = "drop"
+)
+
+// A unit of work stored in the queue
+type Job struct {
+	ID string `json:"id"`
+	// Serialized task spec (e.g. a taskfile.Task, see EnqueueTask)
+	Spec     json.RawMessage `json:"spec"`
+	State    State           `json:"state"`
+	Recovery RecoveryPolicy  `json:"recovery"`
+	// Maximum number of attempts, 0 means unlimited
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	// Number of times the job was leased
+	Attempts   int       `json:"attempts"`
+	Enqueued   time.Time `json:"enqueued"`
+	LeaseUntil time.Time `json:"leaseUntil,omitempty"`
+	// Identifies the current lease, required by Extend, Ack and Nack. A job whose lease
+	// expired and was handed out again cannot be changed with the previous lease id.
+	LeaseID string `json:"leaseId,omitempty"`
+	// Error of the last failed attempt
+	Err string `json:"err,omitempty"`
+
+	// position in the queue, jobs are leased in the order they were enqueued
+	Seq uint64 `json:"seq"`
+}
+
+// Durable job queue backed by a directory
+//   - every change is appended to a write-ahead log (wal.jsonl) and synced before it is applied,
+//     a failed append is truncated again (if that fails too, the queue is unusable until reopened)
+//   - the log is compacted into a snapshot (snapshot.json) every n changes (see WithSnapshotEvery)
+//   - jobs are leased for a limited time, jobs whose lease expired are handed out again
+//     (at-least-once execution, see Ack)
+//   - jobs leased when the queue was last closed or its process crashed are
+//     recovered according to their policy when the queue is opened
+//   - the directory is locked, only one process can open it at a time
+//   - safe for concurrent use
+type queue struct {
+	mu      sync.Mutex
+	dir     string
+	opts    queueOptions
+	lock    *os.File
+	wal     *os.File
+	offset  int64
+	broken  error
+	changes int
+	seq     uint64
+	jobs    map[string]*Job
+	closed  bool
+}
+
+// Open (or create) the queue in dir and recover jobs which were leased at the time it was last used
+func Open(dir string, opts ...Option) (*queue, error) {
+	mergedOpts := queueOptions{
+		snapshotEvery: 1000,
+		sync:          true,
+	}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, "lock"), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("queue: %s is in use by another process: %w", dir, err)
+	}
+
+	q := &queue{dir: dir, opts: mergedOpts, lock: lock, jobs: map[string]*Job{}}
+	if err := q.load(); err != nil {
+		if q.wal != nil {
+			q.wal.Close()
+		}
+		lock.Close()
+		return nil, err
+	}
+	if err := q.recover(); err != nil {
+		q.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// Add a job with a serialized task spec, returns its id
+func (q *queue) Enqueue(spec []byte, opts ...JobOption) (string, error) {
+	mergedOpts := jobOptions{recovery: RecoverRequeue}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+	if !json.Valid(spec) {
+		return "", errors.New("queue: spec is not valid JSON")
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.usableLocked(); err != nil {
+		return "", err
+	}
+
+	q.seq++
+	job := &Job{
+		ID:          output.NewRunID(),
+		Spec:        append(json.RawMessage{}, spec...),
+		State:       StatePending,
+		Recovery:    mergedOpts.recovery,
+		MaxAttempts: mergedOpts.maxAttempts,
+		Enqueued:    time.Now(),
+		Seq:         q.seq,
+	}
+	if err := q.put(job); err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+// Lease the oldest pending job for d. The job has to be acknowledged (Ack) or
+// released (Nack) with its LeaseID before the lease expires (see Extend), otherwise it is handed out again.
+// Returns ErrEmpty if no job is pending.
+func (q *queue) Lease(d time.Duration) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.usableLocked(); err != nil {
+		return Job{}, err
+	}
+
+	now := time.Now()
+	var next *Job
+	for _, job := range q.jobsLocked() {
+		expired := job.State == StateLeased && now.After(job.LeaseUntil)
+		if expired && job.MaxAttempts > 0 && job.Attempts >= job.MaxAttempts {
+			// the lease of the last attempt expired
+			failed := *job
+			failed.State = StateFailed
+			failed.LeaseUntil = time.Time{}
+			failed.LeaseID = ""
+			failed.Err = "lease expired"
+			if err := q.put(&failed); err != nil {
+				return Job{}, err
+			}
+			continue
+		}
+		if next == nil && (job.State == StatePending || expired) {
+			next = job
+		}
+	}
+	if next == nil {
+		return Job{}, ErrEmpty
+	}
+
+	leased := *next
+	leased.State = StateLeased
+	leased.Attempts++
+	leased.LeaseUntil = now.Add(d)
+	leased.LeaseID = output.NewRunID()
+	if err := q.put(&leased); err != nil {
+		return Job{}, err
+	}
+	return leased, nil
+}
+
+// Extend the lease of a job by d from now
+func (q *queue) Extend(id string, leaseID string, d time.Duration) error {
+	return q.update(id, leaseID, func(job *Job) bool {
+		job.LeaseUntil = time.Now().Add(d)
+		return true
+	})
+}
+
+// Mark a leased job as done, it is removed from the queue
+func (q *queue) Ack(id string, leaseID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, err := q.leasedLocked(id, leaseID); err != nil {
+		return err
+	}
+	return q.remove(id)
+}
+
+// Release a leased job after a failed attempt. It is pending again unless its
+// attempts are exhausted, then it is failed.
+func (q *queue) Nack(id string, leaseID string, cause error) error {
+	return q.update(id, leaseID, func(job *Job) bool {
+		job.State = StatePending
+		job.LeaseUntil = time.Time{}
+		job.LeaseID = ""
+		if cause != nil {
+			job.Err = cause.Error()
+		}
+		if job.MaxAttempts > 0 && job.Attempts >= job.MaxAttempts {
+			job.State = StateFailed
+		}
+		return true
+	})
+}
+
+// Remove a job in any state
+func (q *queue) Remove(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.usableLocked(); err != nil {
+		return err
+	}
+	if _, ok := q.jobs[id]; !ok {
+		return fmt.Errorf("%w %q", ErrUnknownJob, id)
+	}
+	return q.remove(id)
+}
+
+// All jobs in the given states (all jobs if none are given), in queue order
+func (q *queue) Jobs(states ...State) []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := []Job{}
+	for _, job := range q.jobs {
+		if len(states) == 0 || containsState(states, job.State) {
+			jobs = append(jobs, *job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Seq < jobs[j].Seq
+	})
+	return jobs
+}
+
+// Write a snapshot and release the directory. Leased jobs are recovered when the queue is opened again.
+func (q *queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	err := q.snapshot()
+	return errors.Join(err, q.wal.Close(), q.lock.Close())
+}
+
+// apply fn to a leased job and persist it
+func (q *queue) update(id string, leaseID string, fn func(job *Job) bool) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, err := q.leasedLocked(id, leaseID)
+	if err != nil {
+		return err
+	}
+	updated := *job
+	if !fn(&updated) {
+		return nil
+	}
+	return q.put(&updated)
+}
+
+// the job with the given id if it is leased with leaseID
+func (q *queue) leasedLocked(id string, leaseID string) (*Job, error) {
+	if err := q.usableLocked(); err != nil {
+		return nil, err
+	}
+	job, ok := q.jobs[id]
+	if !ok || job.State != StateLeased || job.LeaseID != leaseID {
+		return nil, fmt.Errorf("%w %q", ErrUnknownJob, id)
+	}
+	return job, nil
+}
+
+func (q *queue) usableLocked() error {
+	if q.closed {
+		return ErrClosed
+	}
+	if q.broken != nil {
+		return fmt.Errorf("queue: unusable after a failed write: %w", q.broken)
+	}
+	return nil
+}
+
+// apply the recovery policy to jobs leased by a previous process
+func (q *queue) recover() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range q.jobsLocked() {
+		if job.State != StateLeased {
+			continue
+		}
+		recovered := *job
+		recovered.LeaseUntil = time.Time{}
+		recovered.LeaseID = ""
+		switch job.Recovery {
+		case RecoverDrop:
+			if err := q.remove(job.ID); err != nil {
+				return err
+			}
+			continue
+		case RecoverFail:
+			recovered.State = StateFailed
+			recovered.Err = "interrupted"
+		default:
+			recovered.State = StatePending
+			if recovered.MaxAttempts > 0 && recovered.Attempts >= recovered.MaxAttempts {
+				recovered.State = StateFailed
+				recovered.Err = "interrupted"
+			}
+		}
+		if err := q.put(&recovered); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *queue) jobsLocked() []*Job {
+	jobs := []*Job{}
+	for _, job := range q.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Seq < jobs[j].Seq
+	})
+	return jobs
+}
+
+func containsState(states []State, state State) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package queue
+
+import "time"
+
+type queueOptions struct {
+	snapshotEvery int
+	sync          bool
+}
+
+// Options for a queue
+type Option interface {
+	apply(*queueOptions)
+}
+
+type funcOption struct {
+	f func(*queueOptions)
+}
+
+// nolint:unused
+func (fo *funcOption) apply(o *queueOptions) {
+	fo.f(o)
+}
+
+func newFuncOption(f func(*queueOptions)) *funcOption {
+	return &funcOption{f: f}
+}
+
+// Compact the write-ahead log into a snapshot every n changes (default: 1000, 0 only on Close)
+func WithSnapshotEvery(n int) Option {
+	return newFuncOption(func(o *queueOptions) {
+		o.snapshotEvery = n
+	})
+}
+
+// Sync the write-ahead log to disk after every change (default: true).
+// Disabling it is faster, but changes might be lost if the machine crashes.
+func WithSync(sync bool) Option {
+	return newFuncOption(func(o *queueOptions) {
+		o.sync = sync
+	})
+}
+
+type jobOptions struct {
+	recovery    RecoveryPolicy
+	maxAttempts int
+}
+
+// Options for a single job
+type JobOption interface {
+	apply(*jobOptions)
+}
+
+type funcJobOption struct {
+	f func(*jobOptions)
+}
+
+// nolint:unused
+func (fjo *funcJobOption) apply(o *jobOptions) {
+	fjo.f(o)
+}
+
+func newFuncJobOption(f func(*jobOptions)) *funcJobOption {
+	return &funcJobOption{f: f}
+}
+
+// What happens to the job if it was leased when the queue's process ended (default: RecoverRequeue)
+func WithRecovery(policy RecoveryPolicy) JobOption {
+	return newFuncJobOption(func(o *jobOptions) {
+		o.recovery = policy
+	})
+}
+
+// Fail the job after n attempts (default: 0, unlimited)
+func WithMaxAttempts(n int) JobOption {
+	return newFuncJobOption(func(o *jobOptions) {
+		o.maxAttempts = n
+	})
+}
+
+type workerOptions struct {
+	lease        time.Duration
+	pollInterval time.Duration
+	concurrency  int
+}
+
+// Options for Work
+type WorkerOption interface {
+	apply(*workerOptions)
+}
+
+type funcWorkerOption struct {
+	f func(*workerOptions)
+}
+
+// nolint:unused
+func (fwo *funcWorkerOption) apply(o *workerOptions) {
+	fwo.f(o)
+}
+
+func newFuncWorkerOption(f func(*workerOptions)) *funcWorkerOption {
+	return &funcWorkerOption{f: f}
+}
+
+// Duration of a lease, it is extended every half of it while the job is running (default: 30s, must be positive)
+func WithLease(d time.Duration) WorkerOption {
+	return newFuncWorkerOption(func(o *workerOptions) {
+		o.lease = d
+	})
+}
+
+// Time to wait before looking for new jobs if the queue is empty (default: 1s, must be positive)
+func WithPollInterval(d time.Duration) WorkerOption {
+	return newFuncWorkerOption(func(o *workerOptions) {
+		o.pollInterval = d
+	})
+}
+
+// Number of jobs executed at the same time (default: 1, must be at least 1)
+func WithConcurrency(n int) WorkerOption {
+	return newFuncWorkerOption(func(o *workerOptions) {
+		o.concurrency = n
+	})
+}
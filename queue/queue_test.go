@@ -0,0 +1,284 @@
+package queue_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dunv/utask"
+	"github.com/dunv/utask/queue"
+	"github.com/dunv/utask/taskfile"
+	"github.com/stretchr/testify/require"
+)
+
+func specs(jobs []queue.Job) []string {
+	s := []string{}
+	for _, j := range jobs {
+		s = append(s, string(j.Spec))
+	}
+	return s
+}
+
+func TestQueueLeaseAckNack(t *testing.T) {
+	q, err := queue.Open(t.TempDir())
+	require.NoError(t, err)
+	defer q.Close()
+
+	_, err = q.Enqueue([]byte(`"a"`))
+	require.NoError(t, err)
+	_, err = q.Enqueue([]byte(`"b"`), queue.WithMaxAttempts(1))
+	require.NoError(t, err)
+	_, err = q.Enqueue([]byte(`not json`))
+	require.Error(t, err)
+
+	a, err := q.Lease(time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, `"a"`, string(a.Spec))
+	require.Equal(t, 1, a.Attempts)
+
+	b, err := q.Lease(time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, `"b"`, string(b.Spec))
+
+	_, err = q.Lease(time.Minute)
+	require.ErrorIs(t, err, queue.ErrEmpty)
+
+	require.ErrorIs(t, q.Ack(a.ID, b.LeaseID), queue.ErrUnknownJob)
+	require.NoError(t, q.Nack(a.ID, a.LeaseID, errors.New("boom")))
+	require.NoError(t, q.Nack(b.ID, b.LeaseID, errors.New("boom")))
+	require.ErrorIs(t, q.Ack(b.ID, b.LeaseID), queue.ErrUnknownJob)
+
+	failed := q.Jobs(queue.StateFailed)
+	require.Equal(t, []string{`"b"`}, specs(failed))
+	require.Equal(t, "boom", failed[0].Err)
+
+	a, err = q.Lease(time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 2, a.Attempts)
+	require.NoError(t, q.Ack(a.ID, a.LeaseID))
+	require.Equal(t, []string{`"b"`}, specs(q.Jobs()))
+
+	require.NoError(t, q.Remove(b.ID))
+	require.Empty(t, q.Jobs())
+}
+
+func TestQueueLeaseExpiry(t *testing.T) {
+	q, err := queue.Open(t.TempDir())
+	require.NoError(t, err)
+	defer q.Close()
+
+	_, err = q.Enqueue([]byte(`"a"`), queue.WithMaxAttempts(2))
+	require.NoError(t, err)
+
+	first, err := q.Lease(10 * time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	second, err := q.Lease(10 * time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, first.ID, second.ID)
+	require.Equal(t, 2, second.Attempts)
+
+	require.NotEqual(t, first.LeaseID, second.LeaseID)
+
+	// the first worker lost its lease, it cannot change the job anymore
+	require.ErrorIs(t, q.Extend(first.ID, first.LeaseID, time.Minute), queue.ErrUnknownJob)
+	require.ErrorIs(t, q.Nack(first.ID, first.LeaseID, nil), queue.ErrUnknownJob)
+	require.ErrorIs(t, q.Ack(first.ID, first.LeaseID), queue.ErrUnknownJob)
+	require.NoError(t, q.Extend(second.ID, second.LeaseID, time.Minute))
+	require.NoError(t, q.Ack(second.ID, second.LeaseID))
+
+	_, err = q.Enqueue([]byte(`"b"`), queue.WithMaxAttempts(1))
+	require.NoError(t, err)
+	_, err = q.Lease(10 * time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	_, err = q.Lease(10 * time.Millisecond)
+	require.ErrorIs(t, err, queue.ErrEmpty)
+	failed := q.Jobs(queue.StateFailed)
+	require.Len(t, failed, 1)
+	require.Equal(t, "lease expired", failed[0].Err)
+}
+
+func copyDir(t *testing.T, src string) string {
+	dst := t.TempDir()
+	entries, err := os.ReadDir(src)
+	require.NoError(t, err)
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(src, e.Name()))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dst, e.Name()), data, 0o644))
+	}
+	return dst
+}
+
+func TestQueueCrashRecovery(t *testing.T) {
+	for _, snapshotEvery := range []int{0, 2} {
+		dir := t.TempDir()
+		q, err := queue.Open(dir, queue.WithSnapshotEvery(snapshotEvery))
+		require.NoError(t, err)
+
+		_, err = queue.Open(dir)
+		require.ErrorContains(t, err, "in use by another process")
+
+		for _, spec := range []string{`"requeue"`, `"fail"`, `"drop"`, `"pending"`} {
+			policy := queue.RecoveryPolicy(spec[1 : len(spec)-1])
+			if spec == `"pending"` {
+				policy = queue.RecoverRequeue
+			}
+			_, err := q.Enqueue([]byte(spec), queue.WithRecovery(policy))
+			require.NoError(t, err)
+		}
+		for i := 0; i < 3; i++ {
+			_, err := q.Lease(time.Minute)
+			require.NoError(t, err)
+		}
+
+		// simulate a crash by using a copy of the directory while the queue is still open
+		crashed := copyDir(t, dir)
+		require.NoError(t, q.Close())
+
+		// a partially written entry is ignored
+		f, err := os.OpenFile(filepath.Join(crashed, "wal.jsonl"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		require.NoError(t, err)
+		_, err = f.WriteString(`{"job":{"id":"x"`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		recovered, err := queue.Open(crashed)
+		require.NoError(t, err)
+		require.Equal(t, []string{`"requeue"`, `"pending"`}, specs(recovered.Jobs(queue.StatePending)))
+		require.Equal(t, []string{`"fail"`}, specs(recovered.Jobs(queue.StateFailed)))
+		require.Empty(t, recovered.Jobs(queue.StateLeased))
+
+		// new jobs are queued after the recovered ones
+		_, err = recovered.Enqueue([]byte(`"new"`))
+		require.NoError(t, err)
+		require.Equal(t, []string{`"requeue"`, `"pending"`, `"new"`}, specs(recovered.Jobs(queue.StatePending)))
+		require.NoError(t, recovered.Close())
+
+		// a clean close behaves the same
+		reopened, err := queue.Open(dir)
+		require.NoError(t, err)
+		require.Equal(t, []string{`"requeue"`, `"pending"`}, specs(reopened.Jobs(queue.StatePending)))
+		require.NoError(t, reopened.Close())
+	}
+}
+
+func TestQueueWork(t *testing.T) {
+	dir := t.TempDir()
+	q, err := queue.Open(filepath.Join(dir, "queue"))
+	require.NoError(t, err)
+	defer q.Close()
+
+	for _, name := range []string{"one", "two", "three"} {
+		_, err := q.EnqueueTask(taskfile.Task{
+			Name:       name,
+			Command:    "sh",
+			Args:       []string{"-c", "touch " + name},
+			WorkingDir: dir,
+		})
+		require.NoError(t, err)
+	}
+	_, err = q.EnqueueTask(taskfile.Task{Name: "broken", Command: "sh", Args: []string{"-c", "exit 1"}}, queue.WithMaxAttempts(2))
+	require.NoError(t, err)
+	_, err = q.EnqueueTask(taskfile.Task{Name: "invalid"})
+	require.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- q.Work(ctx, queue.TaskHandler(utask.WithShellStderr(io.Discard)), queue.WithConcurrency(2), queue.WithLease(time.Second), queue.WithPollInterval(5*time.Millisecond))
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(q.Jobs(queue.StatePending, queue.StateLeased)) == 0
+	}, 5*time.Second, 5*time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	for _, name := range []string{"one", "two", "three"} {
+		require.FileExists(t, filepath.Join(dir, name))
+	}
+	failed := q.Jobs(queue.StateFailed)
+	require.Len(t, failed, 1)
+	require.Equal(t, 2, failed[0].Attempts)
+	require.Equal(t, "exit status 1", failed[0].Err)
+}
+
+func TestQueueWorkExtendsLease(t *testing.T) {
+	q, err := queue.Open(t.TempDir())
+	require.NoError(t, err)
+	defer q.Close()
+	_, err = q.Enqueue([]byte(`"slow"`))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := 0
+	err = q.Work(ctx, func(ctx context.Context, job queue.Job) error {
+		runs++
+		// runs longer than the lease, which is extended in the meantime
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+		return nil
+	}, queue.WithLease(40*time.Millisecond), queue.WithConcurrency(3), queue.WithPollInterval(5*time.Millisecond))
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 1, runs)
+	require.Empty(t, q.Jobs())
+}
+
+func TestQueueWorkInvalidOptions(t *testing.T) {
+	q, err := queue.Open(t.TempDir())
+	require.NoError(t, err)
+	defer q.Close()
+
+	handler := func(ctx context.Context, job queue.Job) error { return nil }
+	for opt, expected := range map[queue.WorkerOption]string{
+		queue.WithLease(0):                   "queue: lease must be positive, got 0s",
+		queue.WithPollInterval(-time.Second): "queue: poll interval must be positive, got -1s",
+		queue.WithConcurrency(0):             "queue: concurrency must be at least 1, got 0",
+	} {
+		require.EqualError(t, q.Work(context.Background(), handler, opt), expected)
+	}
+}
+
+func TestQueueOpenInvalidLog(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "wal.jsonl"), []byte("garbage\n{}\n"), 0o644))
+	_, err := queue.Open(dir)
+	require.ErrorContains(t, err, "invalid log entry")
+
+	// the directory is released again
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "wal.jsonl"), nil, 0o644))
+	q, err := queue.Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, q.Close())
+}
+
+func TestQueueSnapshotFailure(t *testing.T) {
+	dir := t.TempDir()
+	q, err := queue.Open(dir, queue.WithSnapshotEvery(1))
+	require.NoError(t, err)
+
+	// the snapshot cannot be written
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "snapshot.json.tmp"), 0o755))
+	id, err := q.Enqueue([]byte(`"a"`))
+	require.NoError(t, err)
+	job, err := q.Lease(time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, id, job.ID)
+	require.Error(t, q.Close())
+
+	// all changes are in the log
+	require.NoError(t, os.Remove(filepath.Join(dir, "snapshot.json.tmp")))
+	q, err = queue.Open(dir, queue.WithSnapshotEvery(1))
+	require.NoError(t, err)
+	defer q.Close()
+	jobs := q.Jobs()
+	require.Len(t, jobs, 1)
+	require.Equal(t, id, jobs[0].ID)
+	require.Equal(t, 1, jobs[0].Attempts)
+}
@@ -0,0 +1,179 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	walFileName      = "wal.jsonl"
+	snapshotFileName = "snapshot.json"
+)
+
+// entry of the write-ahead log, either the new state of a job or its removal
+type walEntry struct {
+	Job    *Job   `json:"job,omitempty"`
+	Remove string `json:"remove,omitempty"`
+}
+
+type snapshot struct {
+	Seq  uint64 `json:"seq"`
+	Jobs []*Job `json:"jobs"`
+}
+
+// read the snapshot, replay the log and open it for appending
+func (q *queue) load() error {
+	data, err := os.ReadFile(filepath.Join(q.dir, snapshotFileName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		snap := snapshot{}
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return fmt.Errorf("queue: invalid snapshot: %w", err)
+		}
+		q.seq = snap.Seq
+		for _, job := range snap.Jobs {
+			q.jobs[job.ID] = job
+		}
+	}
+
+	walPath := filepath.Join(q.dir, walFileName)
+	data, err = os.ReadFile(walPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	valid := 0
+	for i, line := range lines {
+		if len(line) == 0 {
+			valid += len(line) + 1
+			continue
+		}
+		entry := walEntry{}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			// a partially written last entry was never applied
+			if i == len(lines)-1 {
+				break
+			}
+			return fmt.Errorf("queue: invalid log entry %s:%d: %w", walPath, i+1, err)
+		}
+		q.apply(entry)
+		q.changes++
+		valid += len(line) + 1
+	}
+
+	q.wal, err = os.OpenFile(walPath, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	// drop a partial entry and continue after the last complete one
+	valid = min(valid, len(data))
+	if err := q.wal.Truncate(int64(valid)); err != nil {
+		return err
+	}
+	q.offset = int64(valid)
+	_, err = q.wal.Seek(q.offset, 0)
+	return err
+}
+
+func (q *queue) apply(entry walEntry) {
+	switch {
+	case entry.Job != nil:
+		q.jobs[entry.Job.ID] = entry.Job
+		q.seq = max(q.seq, entry.Job.Seq)
+	case entry.Remove != "":
+		delete(q.jobs, entry.Remove)
+	}
+}
+
+// persist the new state of a job, then apply it
+func (q *queue) put(job *Job) error {
+	return q.write(walEntry{Job: job})
+}
+
+// persist the removal of a job, then apply it
+func (q *queue) remove(id string) error {
+	return q.write(walEntry{Remove: id})
+}
+
+func (q *queue) write(entry walEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if err := q.append(line); err != nil {
+		// the entry is not applied, remove whatever was written of it
+		if rollbackErr := q.truncate(q.offset); rollbackErr != nil {
+			q.broken = errors.Join(err, rollbackErr)
+		}
+		return err
+	}
+	q.offset += int64(len(line))
+	q.apply(entry)
+
+	q.changes++
+	if q.opts.snapshotEvery > 0 && q.changes >= q.opts.snapshotEvery {
+		// the entry is committed already, a failed snapshot is retried by the next write
+		// (the log keeps all changes) and reported by Close
+		_ = q.snapshot()
+	}
+	return nil
+}
+
+func (q *queue) append(line []byte) error {
+	if _, err := q.wal.Write(line); err != nil {
+		return err
+	}
+	if q.opts.sync {
+		return q.wal.Sync()
+	}
+	return nil
+}
+
+// cut the log at offset and continue writing there
+func (q *queue) truncate(offset int64) error {
+	if err := q.wal.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := q.wal.Seek(offset, 0); err != nil {
+		return err
+	}
+	q.offset = offset
+	return nil
+}
+
+// write all jobs to the snapshot and empty the log
+func (q *queue) snapshot() error {
+	snap := snapshot{Seq: q.seq, Jobs: q.jobsLocked()}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(q.dir, snapshotFileName+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err := errors.Join(err, f.Sync(), f.Close()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(q.dir, snapshotFileName)); err != nil {
+		return err
+	}
+
+	// entries of the log are contained in the snapshot now
+	if err := q.truncate(0); err != nil {
+		return err
+	}
+	q.changes = 0
+	return nil
+}
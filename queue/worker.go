@@ -0,0 +1,152 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dunv/utask"
+	"github.com/dunv/utask/taskfile"
+)
+
+// Executes a job, a returned error releases the job for another attempt (see Nack)
+type Handler func(ctx context.Context, job Job) error
+
+// Lease and execute jobs until ctx is done
+//   - the lease of a running job is extended periodically, if that fails
+//     (e.g. the lease expired) the handler's context is canceled
+//   - jobs are acknowledged if the handler succeeded and released otherwise
+//
+// Returns ctx.Err() once all running handlers returned, or the first error of the queue itself.
+// Invalid options (see WithLease, WithPollInterval, WithConcurrency) are returned immediately.
+func (q *queue) Work(ctx context.Context, handler Handler, opts ...WorkerOption) error {
+	mergedOpts := workerOptions{
+		lease:        30 * time.Second,
+		pollInterval: time.Second,
+		concurrency:  1,
+	}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+	if mergedOpts.lease <= 0 {
+		return fmt.Errorf("queue: lease must be positive, got %s", mergedOpts.lease)
+	}
+	if mergedOpts.pollInterval <= 0 {
+		return fmt.Errorf("queue: poll interval must be positive, got %s", mergedOpts.pollInterval)
+	}
+	if mergedOpts.concurrency < 1 {
+		return fmt.Errorf("queue: concurrency must be at least 1, got %d", mergedOpts.concurrency)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < mergedOpts.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				job, err := q.Lease(mergedOpts.lease)
+				if errors.Is(err, ErrEmpty) {
+					select {
+					case <-ctx.Done():
+					case <-time.After(mergedOpts.pollInterval):
+					}
+					continue
+				}
+				if err != nil {
+					cancel(err)
+					return
+				}
+				if err := q.execute(ctx, job, handler, mergedOpts.lease); err != nil {
+					cancel(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return context.Cause(ctx)
+}
+
+// run handler for a leased job while extending its lease, then acknowledge or release it
+func (q *queue) execute(ctx context.Context, job Job, handler Handler, lease time.Duration) error {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		ticker := time.NewTicker(max(lease/2, time.Nanosecond))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-jobCtx.Done():
+				return
+			case <-ticker.C:
+				if err := q.Extend(job.ID, job.LeaseID, lease); err != nil {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	err := handler(jobCtx, job)
+	lost := jobCtx.Err() != nil && ctx.Err() == nil
+	cancel()
+	<-heartbeatDone
+
+	switch {
+	case err == nil:
+		err = q.Ack(job.ID, job.LeaseID)
+	case lost:
+		// the lease was lost, the job is handed out again
+		return nil
+	default:
+		// also if stopped, so the job does not wait for its lease to expire
+		err = q.Nack(job.ID, job.LeaseID, err)
+	}
+	if errors.Is(err, ErrUnknownJob) {
+		// lease expired and the job was taken over
+		return nil
+	}
+	return err
+}
+
+// Add a job running the task definition as a shell task (see TaskHandler)
+func (q *queue) EnqueueTask(t taskfile.Task, opts ...JobOption) (string, error) {
+	if err := t.Validate(); err != nil {
+		return "", err
+	}
+	spec, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	return q.Enqueue(spec, opts...)
+}
+
+// Handler running jobs added with EnqueueTask as shell tasks, opts are added to
+// the options derived from the definition (e.g. for output routing)
+func TaskHandler(opts ...utask.ShellTaskOption) Handler {
+	return func(ctx context.Context, job Job) error {
+		t := taskfile.Task{}
+		if err := json.Unmarshal(job.Spec, &t); err != nil {
+			return fmt.Errorf("queue: job %s: invalid task spec: %w", job.ID, err)
+		}
+		if t.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(t.Timeout))
+			defer cancel()
+		}
+		task, err := t.ShellTask(ctx, opts...)
+		if err != nil {
+			return err
+		}
+		return task.Run()
+	}
+}
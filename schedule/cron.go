@@ -0,0 +1,285 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Determines when a task runs
+type Schedule interface {
+	// Next activation time strictly after t, zero if there is none
+	Next(t time.Time) time.Time
+}
+
+// Parse a schedule
+//   - standard cron expressions with 5 fields (minute hour day-of-month month day-of-week)
+//     or 6 fields (with a leading second field)
+//   - fields support *, ?, lists (1,2), ranges (1-5), steps (*/15, 1-30/5) and
+//     names for months (JAN-DEC) and days of the week (SUN-SAT, 0 and 7 are sunday)
+//   - descriptors @yearly (@annually), @monthly, @weekly, @daily (@midnight), @hourly
+//   - @every <duration> (e.g. @every 1h30m)
+//   - a leading CRON_TZ=<zone> (or TZ=<zone>) evaluates the schedule in the given time zone,
+//     otherwise the location of the time passed to Next is used
+//   - activations falling into a daylight saving time gap are skipped
+func Parse(spec string) (Schedule, error) {
+	return parse(spec, nil)
+}
+
+func parse(spec string, defaultLocation *time.Location) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	loc := defaultLocation
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		zone, rest, _ := strings.Cut(spec, " ")
+		_, name, _ := strings.Cut(zone, "=")
+		var err error
+		if loc, err = time.LoadLocation(name); err != nil {
+			return nil, fmt.Errorf("schedule: invalid time zone %q: %w", name, err)
+		}
+		spec = strings.TrimSpace(rest)
+	}
+
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("schedule: invalid @every duration: %w", err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("schedule: @every duration must be at least 1s, got %s", d)
+		}
+		return everySchedule{interval: d}, nil
+	}
+
+	if descriptor, ok := descriptors[spec]; ok {
+		spec = descriptor
+	} else if strings.HasPrefix(spec, "@") {
+		return nil, fmt.Errorf("schedule: unknown descriptor %q", spec)
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("schedule: expected 5 or 6 fields, got %d in %q", len(fields), spec)
+	}
+
+	s := &cronSchedule{loc: loc}
+	var err error
+	for i, target := range []*uint64{&s.second, &s.minute, &s.hour, &s.dom, &s.month, &s.dow} {
+		if *target, err = parseField(fields[i], cronFields[i]); err != nil {
+			return nil, err
+		}
+	}
+	s.domStar = isStar(fields[3])
+	s.dowStar = isStar(fields[5])
+
+	// 7 is an alias for sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	return s, nil
+}
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var cronFields = []cronField{
+	{name: "second", min: 0, max: 59},
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day-of-month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	{name: "day-of-week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+func isStar(field string) bool {
+	return field == "*" || field == "?"
+}
+
+// parse a field into a bitset of allowed values
+func parseField(field string, f cronField) (uint64, error) {
+	bits := uint64(0)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		start, end := f.min, f.max
+		if !isStar(rangePart) {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = parseValue(from, f); err != nil {
+				return 0, err
+			}
+			end = start
+			if isRange {
+				if end, err = parseValue(to, f); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// "5/10" means "5-max/10"
+				end = f.max
+			}
+			if start > end {
+				return 0, fmt.Errorf("schedule: invalid range %q in %s field", rangePart, f.name)
+			}
+		}
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return 0, fmt.Errorf("schedule: invalid step %q in %s field", stepPart, f.name)
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, f cronField) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("schedule: invalid value %q in %s field (allowed: %d-%d)", s, f.name, f.min, f.max)
+	}
+	return v, nil
+}
+
+type cronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	// if both day fields are restricted, a day matches if either field matches
+	domStar, dowStar bool
+	loc              *time.Location
+}
+
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	origLocation := t.Location()
+	loc := s.loc
+	if loc == nil {
+		loc = origLocation
+	}
+	t = t.In(loc)
+
+	// start with the next whole second
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+
+	// whether a field was incremented already (lower fields have been reset then)
+	added := false
+
+	// give up if there is no match within 5 years (e.g. 30th of february)
+	yearLimit := t.Year() + 5
+
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for s.month&(1<<uint(t.Month())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+
+	for !s.dayMatches(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 0, 1)
+		// midnight might not exist or be shifted because of daylight saving time
+		if t.Hour() != 0 {
+			if t.Hour() > 12 {
+				t = t.Add(time.Duration(24-t.Hour()) * time.Hour)
+			} else {
+				t = t.Add(time.Duration(-t.Hour()) * time.Hour)
+			}
+		}
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+
+	for s.hour&(1<<uint(t.Hour())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+
+	for s.minute&(1<<uint(t.Minute())) == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+
+	for s.second&(1<<uint(t.Second())) == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+
+	return t.In(origLocation)
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+type everySchedule struct {
+	interval time.Duration
+}
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval - time.Duration(t.Nanosecond()))
+}
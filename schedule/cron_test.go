@@ -0,0 +1,69 @@
+package schedule_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dunv/utask/schedule"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNext(t *testing.T) {
+	start := time.Date(2024, 1, 31, 10, 17, 30, 0, time.UTC)
+	for _, tc := range []struct {
+		spec string
+		next []string
+	}{
+		{"*/15 * * * *", []string{"2024-01-31T10:30:00Z", "2024-01-31T10:45:00Z", "2024-01-31T11:00:00Z"}},
+		{"0 9 * * MON-FRI", []string{"2024-02-01T09:00:00Z", "2024-02-02T09:00:00Z", "2024-02-05T09:00:00Z"}},
+		{"0 0 31 * *", []string{"2024-03-31T00:00:00Z", "2024-05-31T00:00:00Z"}},
+		{"0 0 29 FEB *", []string{"2024-02-29T00:00:00Z", "2028-02-29T00:00:00Z"}},
+		{"0 0 1 * 7", []string{"2024-02-01T00:00:00Z", "2024-02-04T00:00:00Z"}},
+		{"30 */20 * * * *", []string{"2024-01-31T10:20:30Z", "2024-01-31T10:40:30Z"}},
+		{"@daily", []string{"2024-02-01T00:00:00Z", "2024-02-02T00:00:00Z"}},
+		{"@every 90m", []string{"2024-01-31T11:47:30Z", "2024-01-31T13:17:30Z"}},
+		{"CRON_TZ=Europe/Berlin 0 12 * * *", []string{"2024-01-31T11:00:00Z", "2024-02-01T11:00:00Z"}},
+	} {
+		t.Run(tc.spec, func(t *testing.T) {
+			s, err := schedule.Parse(tc.spec)
+			require.NoError(t, err)
+			cur := start
+			for _, expected := range tc.next {
+				cur = s.Next(cur)
+				require.Equal(t, expected, cur.UTC().Format(time.RFC3339))
+			}
+		})
+	}
+}
+
+func TestParseDST(t *testing.T) {
+	// 02:30 does not exist on 2024-03-31 in Berlin, that day is skipped
+	s, err := schedule.Parse("CRON_TZ=Europe/Berlin 30 2 * * *")
+	require.NoError(t, err)
+	next := s.Next(time.Date(2024, 3, 30, 12, 0, 0, 0, time.UTC))
+	require.Equal(t, "2024-04-01T00:30:00Z", next.UTC().Format(time.RFC3339))
+}
+
+func TestParseNever(t *testing.T) {
+	s, err := schedule.Parse("0 0 30 2 *")
+	require.NoError(t, err)
+	require.True(t, s.Next(time.Now()).IsZero())
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * *",
+		"60 * * * *",
+		"* * * 13 *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * * FOO",
+		"@sometimes",
+		"@every 10ms",
+		"CRON_TZ=Nowhere/Special * * * * *",
+	} {
+		_, err := schedule.Parse(spec)
+		require.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,360 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/dunv/utask"
+)
+
+// What happens if a schedule fires while the previous run is still going
+type OverlapPolicy int
+
+const (
+	// Do not start a new run (default)
+	Skip OverlapPolicy = iota
+	// Start the new run once the previous one ended
+	Queue
+	// Cancel the previous run (via its context) and start the new one once it ended
+	CancelPrevious
+)
+
+// What happens to activations missed while the scheduler was not running
+type CatchUpPolicy int
+
+const (
+	// Missed activations are dropped (default)
+	CatchUpIgnore CatchUpPolicy = iota
+	// Run once for the most recent missed activation
+	CatchUpOnce
+	// Run once for every missed activation, one after another
+	CatchUpAll
+)
+
+// Outcome of a single firing of a schedule
+type Result struct {
+	Name string
+	// Activation time according to the schedule (without jitter)
+	Scheduled time.Time
+	// Zero if the run was skipped
+	Started time.Time
+	Ended   time.Time
+	// True if the run was skipped because of the overlap policy
+	Skipped bool
+	// True if the activation was missed while the scheduler was not running (see WithCatchUp)
+	CatchUp bool
+	// Error creating or running the task
+	Err error
+	// Error persisting the activation to the state file (see WithStateFile)
+	StateErr error
+}
+
+// Runs tasks according to their schedules
+//   - for every activation a fresh task is created by the entry's factory
+//   - overlapping runs are handled by the entry's overlap policy
+//   - all times are taken from the clock (see WithClock)
+type scheduler struct {
+	mu      sync.Mutex
+	opts    schedulerOptions
+	entries map[string]*entry
+	order   []string
+	running bool
+	state   *stateFile
+}
+
+// Create a scheduler, entries are added with Add and run by Run
+func New(opts ...Option) *scheduler {
+	mergedOpts := schedulerOptions{
+		clock:    realClock{},
+		location: time.Local,
+	}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+
+	return &scheduler{
+		opts:    mergedOpts,
+		entries: map[string]*entry{},
+	}
+}
+
+// Add a schedule (see Parse for the syntax of spec) creating tasks with factory.
+// Names must be unique. Entries can only be added before Run is called.
+func (s *scheduler) Add(name string, spec string, factory utask.TaskFactory, opts ...EntryOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return errors.New("schedule: cannot add entries to a running scheduler")
+	}
+	if _, ok := s.entries[name]; ok {
+		return fmt.Errorf("schedule: duplicate entry %q", name)
+	}
+	if factory == nil {
+		return fmt.Errorf("schedule: no factory given for entry %q", name)
+	}
+
+	schedule, err := parse(spec, s.opts.location)
+	if err != nil {
+		return fmt.Errorf("schedule: entry %q: %w", name, err)
+	}
+
+	mergedOpts := entryOptions{
+		overlap:         Skip,
+		catchUp:         CatchUpIgnore,
+		catchUpLookback: 24 * time.Hour,
+	}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+
+	s.entries[name] = &entry{
+		name:      name,
+		schedule:  schedule,
+		factory:   factory,
+		opts:      mergedOpts,
+		scheduler: s,
+	}
+	s.order = append(s.order, name)
+	return nil
+}
+
+// Run all entries until ctx is done. Running tasks are then canceled (via the
+// context supplied to their factory) and waited for.
+// If a state file is configured, missed activations are caught up on first (see WithCatchUp).
+func (s *scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return errors.New("schedule: already running")
+	}
+	if err := s.loadState(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.running = true
+	entries := []*entry{}
+	for _, name := range s.order {
+		entries = append(entries, s.entries[name])
+	}
+	s.mu.Unlock()
+
+	wg := sync.WaitGroup{}
+	for _, e := range entries {
+		wg.Add(1)
+		go func(e *entry) {
+			defer wg.Done()
+			e.loop(ctx)
+		}(e)
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	s.running = false
+	s.mu.Unlock()
+	return ctx.Err()
+}
+
+// read the state file, entries without state start counting from now
+func (s *scheduler) loadState() error {
+	if s.opts.statePath == "" {
+		return nil
+	}
+	state, err := ReadState(s.opts.statePath)
+	if err != nil {
+		return err
+	}
+
+	s.state = &stateFile{path: s.opts.statePath, state: state}
+	now := s.opts.clock.Now()
+	changed := false
+	for _, name := range s.order {
+		if _, ok := state.Entries[name]; !ok {
+			state.Entries[name] = EntryState{LastFired: now}
+			changed = true
+		}
+	}
+	if changed {
+		return s.state.writeLocked()
+	}
+	return nil
+}
+
+// Next activation time of the entry with the given name (zero if unknown)
+func (s *scheduler) Next(name string) time.Time {
+	s.mu.Lock()
+	e, ok := s.entries[name]
+	s.mu.Unlock()
+	if !ok {
+		return time.Time{}
+	}
+	return e.schedule.Next(s.opts.clock.Now())
+}
+
+func (s *scheduler) report(r Result) {
+	if s.opts.resultHook != nil {
+		s.opts.resultHook(r)
+	}
+}
+
+type entry struct {
+	name      string
+	schedule  Schedule
+	factory   utask.TaskFactory
+	opts      entryOptions
+	scheduler *scheduler
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	queued  []activation
+	runs    sync.WaitGroup
+	running bool
+}
+
+type activation struct {
+	scheduled time.Time
+	catchUp   bool
+}
+
+// wait for activations until ctx is done
+func (e *entry) loop(ctx context.Context) {
+	clock := e.scheduler.opts.clock
+	last := clock.Now()
+	e.catchUp(ctx, last)
+	for {
+		next := e.schedule.Next(last)
+		if next.IsZero() {
+			break
+		}
+
+		delay := next.Sub(clock.Now())
+		if e.opts.jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(e.opts.jitter)))
+		}
+
+		timer := clock.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			e.runs.Wait()
+			return
+		case <-timer.C():
+		}
+
+		last = next
+		e.fire(ctx, activation{scheduled: next})
+	}
+
+	<-ctx.Done()
+	e.runs.Wait()
+}
+
+// fire activations missed since the last persisted one according to the catch-up policy
+func (e *entry) catchUp(ctx context.Context, now time.Time) {
+	state := e.scheduler.state
+	if state == nil || e.opts.catchUp == CatchUpIgnore {
+		return
+	}
+	last, ok := state.lastFired(e.name)
+	if !ok {
+		return
+	}
+	if e.opts.catchUpLookback > 0 && now.Sub(last) > e.opts.catchUpLookback {
+		last = now.Add(-e.opts.catchUpLookback)
+	}
+
+	missed := []activation{}
+	for next := e.schedule.Next(last); !next.IsZero() && !next.After(now); next = e.schedule.Next(next) {
+		missed = append(missed, activation{scheduled: next, catchUp: true})
+	}
+	if len(missed) == 0 {
+		return
+	}
+
+	if e.opts.catchUp == CatchUpOnce {
+		e.fire(ctx, missed[len(missed)-1])
+		return
+	}
+
+	// all missed runs are executed one after another, independent of the overlap policy.
+	// They are queued before the first one starts, so it cannot end with an empty queue.
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.queued = append(e.queued, missed...)
+	if !e.running {
+		next := e.queued[0]
+		e.queued = e.queued[1:]
+		e.startLocked(ctx, next)
+	}
+}
+
+// handle an activation according to the overlap policy
+func (e *entry) fire(ctx context.Context, a activation) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.running {
+		e.startLocked(ctx, a)
+		return
+	}
+
+	switch e.opts.overlap {
+	case Queue:
+		e.queued = append(e.queued, a)
+	case CancelPrevious:
+		e.queued = []activation{a}
+		e.cancel()
+	default:
+		// skipped activations are not persisted, they did not run
+		e.scheduler.report(Result{Name: e.name, Scheduled: a.scheduled, CatchUp: a.catchUp, Skipped: true})
+	}
+}
+
+// persist the activation and start a run, called with e.mu held.
+// Activations are only persisted once they actually run.
+func (e *entry) startLocked(ctx context.Context, a activation) {
+	stateErr := e.persist(a)
+	runCtx, cancel := context.WithCancel(ctx)
+	e.cancel = cancel
+	e.running = true
+	e.runs.Add(1)
+
+	go func() {
+		defer e.runs.Done()
+		defer cancel()
+
+		r := Result{Name: e.name, Scheduled: a.scheduled, CatchUp: a.catchUp, StateErr: stateErr, Started: e.scheduler.opts.clock.Now()}
+		r.Err = e.run(runCtx)
+		r.Ended = e.scheduler.opts.clock.Now()
+		e.scheduler.report(r)
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.running = false
+		if len(e.queued) > 0 && ctx.Err() == nil {
+			next := e.queued[0]
+			e.queued = e.queued[1:]
+			e.startLocked(ctx, next)
+		}
+	}()
+}
+
+func (e *entry) persist(a activation) error {
+	if e.scheduler.state == nil {
+		return nil
+	}
+	return e.scheduler.state.fired(e.name, a.scheduled)
+}
+
+func (e *entry) run(ctx context.Context) error {
+	task, err := e.factory(ctx)
+	if err != nil {
+		return err
+	}
+	return task.Run()
+}
@@ -0,0 +1,113 @@
+package schedule
+
+import "time"
+
+type schedulerOptions struct {
+	clock      Clock
+	location   *time.Location
+	resultHook func(Result)
+	statePath  string
+}
+
+// Options for a scheduler
+type Option interface {
+	apply(*schedulerOptions)
+}
+
+type funcOption struct {
+	f func(*schedulerOptions)
+}
+
+// nolint:unused
+func (fo *funcOption) apply(o *schedulerOptions) {
+	fo.f(o)
+}
+
+func newFuncOption(f func(*schedulerOptions)) *funcOption {
+	return &funcOption{f: f}
+}
+
+// Source of time (default: the system clock), usually replaced for testing
+func WithClock(clock Clock) Option {
+	return newFuncOption(func(o *schedulerOptions) {
+		o.clock = clock
+	})
+}
+
+// Time zone schedules are evaluated in, unless they specify CRON_TZ (default: time.Local)
+func WithLocation(loc *time.Location) Option {
+	return newFuncOption(func(o *schedulerOptions) {
+		o.location = loc
+	})
+}
+
+// Function called with the outcome of every activation (including skipped ones)
+func WithResultHook(hook func(Result)) Option {
+	return newFuncOption(func(o *schedulerOptions) {
+		o.resultHook = hook
+	})
+}
+
+// Persist the last activation of every entry to the given file, so missed activations
+// can be caught up on after a restart (see WithCatchUp). Entries without state
+// start counting from the time the scheduler is started.
+func WithStateFile(path string) Option {
+	return newFuncOption(func(o *schedulerOptions) {
+		o.statePath = path
+	})
+}
+
+type entryOptions struct {
+	overlap         OverlapPolicy
+	jitter          time.Duration
+	catchUp         CatchUpPolicy
+	catchUpLookback time.Duration
+}
+
+// Options for a single entry of a scheduler
+type EntryOption interface {
+	apply(*entryOptions)
+}
+
+type funcEntryOption struct {
+	f func(*entryOptions)
+}
+
+// nolint:unused
+func (feo *funcEntryOption) apply(o *entryOptions) {
+	feo.f(o)
+}
+
+func newFuncEntryOption(f func(*entryOptions)) *funcEntryOption {
+	return &funcEntryOption{f: f}
+}
+
+// What happens if the schedule fires while the previous run is still going (default: Skip)
+func WithOverlap(policy OverlapPolicy) EntryOption {
+	return newFuncEntryOption(func(o *entryOptions) {
+		o.overlap = policy
+	})
+}
+
+// Delay every run by a random duration between 0 and max
+// (spreads load if many schedules fire at the same time)
+func WithJitter(max time.Duration) EntryOption {
+	return newFuncEntryOption(func(o *entryOptions) {
+		o.jitter = max
+	})
+}
+
+// What happens to activations missed while the scheduler was not running (requires WithStateFile, default: CatchUpIgnore)
+func WithCatchUp(policy CatchUpPolicy) EntryOption {
+	return newFuncEntryOption(func(o *entryOptions) {
+		o.catchUp = policy
+	})
+}
+
+// Only catch up on activations missed within the given duration before the scheduler
+// was started (default: 24h, 0 means no limit)
+func WithCatchUpLookback(d time.Duration) EntryOption {
+	return newFuncEntryOption(func(o *entryOptions) {
+		o.catchUpLookback = d
+	})
+}
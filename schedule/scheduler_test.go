@@ -0,0 +1,409 @@
+package schedule_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dunv/utask"
+	"github.com/dunv/utask/schedule"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	c := &fakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) schedule.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- c.now
+	} else {
+		c.timers = append(c.timers, t)
+		c.cond.Broadcast()
+	}
+	return t
+}
+
+// wait until n timers are pending
+func (c *fakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// advance the time, firing all timers which expired
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := []*fakeTimer{}
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+		} else {
+			t.c <- c.now
+		}
+	}
+	c.timers = pending
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Time
+	c     chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, other := range t.clock.timers {
+		if other == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// task factory whose runs block until released
+type blockingFactory struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingFactory() *blockingFactory {
+	return &blockingFactory{started: make(chan struct{}, 10), release: make(chan struct{}, 10)}
+}
+
+func (f *blockingFactory) factory(ctx context.Context) (utask.Task, error) {
+	return utask.NewFunctionTask(
+		utask.WithFunctionContext(ctx),
+		utask.WithFunction(func(ctx context.Context, stdout, stderr io.Writer) error {
+			f.started <- struct{}{}
+			select {
+			case <-f.release:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}),
+	)
+}
+
+type results struct {
+	c chan schedule.Result
+}
+
+func newResults() *results {
+	return &results{c: make(chan schedule.Result, 10)}
+}
+
+func (r *results) hook(res schedule.Result) {
+	r.c <- res
+}
+
+func startScheduler(t *testing.T, s interface{ Run(context.Context) error }) context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		require.ErrorIs(t, s.Run(ctx), context.Canceled)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return cancel
+}
+
+func TestSchedulerRuns(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+	res := newResults()
+	s := schedule.New(schedule.WithClock(clock), schedule.WithResultHook(res.hook))
+	require.NoError(t, s.Add("every-minute", "* * * * *", func(ctx context.Context) (utask.Task, error) {
+		return utask.NewFunctionTask(utask.WithFunction(func(ctx context.Context, stdout, stderr io.Writer) error {
+			return nil
+		}))
+	}))
+	require.NoError(t, s.Add("broken", "*/2 * * * *", func(ctx context.Context) (utask.Task, error) {
+		return nil, errors.New("broken")
+	}))
+	require.Equal(t, time.Date(2024, 1, 1, 10, 2, 0, 0, time.UTC), s.Next("broken"))
+	startScheduler(t, s)
+
+	clock.BlockUntil(2)
+	clock.Advance(time.Minute)
+	r := <-res.c
+	require.Equal(t, "every-minute", r.Name)
+	require.Equal(t, time.Date(2024, 1, 1, 10, 1, 0, 0, time.UTC), r.Scheduled)
+	require.NoError(t, r.Err)
+
+	clock.BlockUntil(2)
+	clock.Advance(time.Minute)
+	got := map[string]error{}
+	for i := 0; i < 2; i++ {
+		r := <-res.c
+		got[r.Name] = r.Err
+	}
+	require.NoError(t, got["every-minute"])
+	require.EqualError(t, got["broken"], "broken")
+}
+
+func TestSchedulerOverlapSkip(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+	res := newResults()
+	f := newBlockingFactory()
+	s := schedule.New(schedule.WithClock(clock), schedule.WithResultHook(res.hook))
+	require.NoError(t, s.Add("slow", "@every 1m", f.factory))
+	startScheduler(t, s)
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	<-f.started
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	r := <-res.c
+	require.True(t, r.Skipped)
+	require.Equal(t, time.Date(2024, 1, 1, 10, 2, 0, 0, time.UTC), r.Scheduled)
+
+	f.release <- struct{}{}
+	r = <-res.c
+	require.False(t, r.Skipped)
+	require.NoError(t, r.Err)
+}
+
+func TestSchedulerOverlapQueue(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+	res := newResults()
+	f := newBlockingFactory()
+	s := schedule.New(schedule.WithClock(clock), schedule.WithResultHook(res.hook))
+	require.NoError(t, s.Add("slow", "@every 1m", f.factory, schedule.WithOverlap(schedule.Queue)))
+	startScheduler(t, s)
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	<-f.started
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	clock.BlockUntil(1)
+
+	f.release <- struct{}{}
+	r := <-res.c
+	require.Equal(t, time.Date(2024, 1, 1, 10, 1, 0, 0, time.UTC), r.Scheduled)
+	<-f.started
+	f.release <- struct{}{}
+	r = <-res.c
+	require.Equal(t, time.Date(2024, 1, 1, 10, 2, 0, 0, time.UTC), r.Scheduled)
+	require.NoError(t, r.Err)
+}
+
+func TestSchedulerOverlapCancelPrevious(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+	res := newResults()
+	f := newBlockingFactory()
+	s := schedule.New(schedule.WithClock(clock), schedule.WithResultHook(res.hook))
+	require.NoError(t, s.Add("slow", "@every 1m", f.factory, schedule.WithOverlap(schedule.CancelPrevious)))
+	startScheduler(t, s)
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	<-f.started
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+
+	r := <-res.c
+	require.ErrorIs(t, r.Err, context.Canceled)
+	<-f.started
+	f.release <- struct{}{}
+	r = <-res.c
+	require.Equal(t, time.Date(2024, 1, 1, 10, 2, 0, 0, time.UTC), r.Scheduled)
+	require.NoError(t, r.Err)
+}
+
+func TestSchedulerStopCancelsRuns(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+	res := newResults()
+	f := newBlockingFactory()
+	s := schedule.New(schedule.WithClock(clock), schedule.WithResultHook(res.hook))
+	require.NoError(t, s.Add("slow", "@hourly", f.factory))
+	cancel := startScheduler(t, s)
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	<-f.started
+	cancel()
+	r := <-res.c
+	require.ErrorIs(t, r.Err, context.Canceled)
+}
+
+func TestSchedulerAddErrors(t *testing.T) {
+	s := schedule.New()
+	factory := func(ctx context.Context) (utask.Task, error) { return nil, nil }
+	require.NoError(t, s.Add("a", "@daily", factory))
+	require.Error(t, s.Add("a", "@daily", factory))
+	require.Error(t, s.Add("b", "not a schedule", factory))
+	require.Error(t, s.Add("c", "@daily", nil))
+}
+
+func writeState(t *testing.T, path string, lastFired map[string]time.Time) {
+	state := schedule.State{Entries: map[string]schedule.EntryState{}}
+	for name, last := range lastFired {
+		state.Entries[name] = schedule.EntryState{LastFired: last}
+	}
+	data, err := json.Marshal(state)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+}
+
+func noopFactory(ctx context.Context) (utask.Task, error) {
+	return utask.NewFunctionTask(utask.WithFunction(func(ctx context.Context, stdout, stderr io.Writer) error {
+		return nil
+	}))
+}
+
+func TestSchedulerCatchUp(t *testing.T) {
+	now := time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name     string
+		opts     []schedule.EntryOption
+		expected []time.Time
+	}{
+		{"ignore", nil, nil},
+		{"once", []schedule.EntryOption{schedule.WithCatchUp(schedule.CatchUpOnce)}, []time.Time{
+			time.Date(2024, 1, 3, 2, 0, 0, 0, time.UTC),
+		}},
+		{"all", []schedule.EntryOption{schedule.WithCatchUp(schedule.CatchUpAll), schedule.WithCatchUpLookback(0)}, []time.Time{
+			time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 2, 2, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 3, 2, 0, 0, 0, time.UTC),
+		}},
+		{"all within lookback", []schedule.EntryOption{schedule.WithCatchUp(schedule.CatchUpAll), schedule.WithCatchUpLookback(34 * time.Hour)}, []time.Time{
+			time.Date(2024, 1, 2, 2, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 3, 2, 0, 0, 0, time.UTC),
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			statePath := filepath.Join(t.TempDir(), "state.json")
+			writeState(t, statePath, map[string]time.Time{"nightly": time.Date(2023, 12, 31, 2, 0, 0, 0, time.UTC)})
+
+			clock := newFakeClock(now)
+			res := newResults()
+			s := schedule.New(schedule.WithClock(clock), schedule.WithResultHook(res.hook), schedule.WithStateFile(statePath))
+			require.NoError(t, s.Add("nightly", "0 2 * * *", noopFactory, tc.opts...))
+			startScheduler(t, s)
+
+			for _, expected := range tc.expected {
+				r := <-res.c
+				require.True(t, r.CatchUp)
+				require.Equal(t, expected, r.Scheduled)
+				require.NoError(t, r.Err)
+				require.NoError(t, r.StateErr)
+			}
+			clock.BlockUntil(1)
+			select {
+			case r := <-res.c:
+				t.Fatalf("unexpected run %v", r)
+			default:
+			}
+
+			state, err := schedule.ReadState(statePath)
+			require.NoError(t, err)
+			last := time.Date(2023, 12, 31, 2, 0, 0, 0, time.UTC)
+			if len(tc.expected) > 0 {
+				last = tc.expected[len(tc.expected)-1]
+			}
+			require.True(t, last.Equal(state.Entries["nightly"].LastFired))
+		})
+	}
+}
+
+func TestSchedulerStatePersisted(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	clock := newFakeClock(now)
+	res := newResults()
+	s := schedule.New(schedule.WithClock(clock), schedule.WithResultHook(res.hook), schedule.WithStateFile(statePath))
+	require.NoError(t, s.Add("hourly", "@hourly", noopFactory))
+	startScheduler(t, s)
+
+	// entries without state start counting from now
+	clock.BlockUntil(1)
+	state, err := schedule.ReadState(statePath)
+	require.NoError(t, err)
+	require.True(t, now.Equal(state.Entries["hourly"].LastFired))
+
+	clock.Advance(time.Hour)
+	r := <-res.c
+	require.NoError(t, r.StateErr)
+	state, err = schedule.ReadState(statePath)
+	require.NoError(t, err)
+	require.True(t, now.Add(time.Hour).Equal(state.Entries["hourly"].LastFired))
+}
+
+func TestSchedulerSkippedNotPersisted(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	clock := newFakeClock(now)
+	res := newResults()
+	f := newBlockingFactory()
+	s := schedule.New(schedule.WithClock(clock), schedule.WithResultHook(res.hook), schedule.WithStateFile(statePath))
+	require.NoError(t, s.Add("slow", "@every 1m", f.factory))
+	startScheduler(t, s)
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	<-f.started
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	r := <-res.c
+	require.True(t, r.Skipped)
+
+	// only the activation which actually ran is persisted
+	state, err := schedule.ReadState(statePath)
+	require.NoError(t, err)
+	require.True(t, now.Add(time.Minute).Equal(state.Entries["slow"].LastFired))
+
+	f.release <- struct{}{}
+	r = <-res.c
+	require.NoError(t, r.Err)
+}
+
+func TestSchedulerInvalidStateFile(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, os.WriteFile(statePath, []byte("{"), 0o644))
+	s := schedule.New(schedule.WithStateFile(statePath))
+	require.NoError(t, s.Add("hourly", "@hourly", noopFactory))
+	require.ErrorContains(t, s.Run(context.Background()), "invalid state file")
+}
@@ -0,0 +1,84 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Persisted state of all entries of a scheduler (see WithStateFile)
+type State struct {
+	Entries map[string]EntryState `json:"entries"`
+}
+
+// Persisted state of a single entry
+type EntryState struct {
+	// Activation time (according to the schedule) of the last run that was fired
+	LastFired time.Time `json:"lastFired"`
+}
+
+// Read the state file of a scheduler. A missing file results in an empty state.
+func ReadState(path string) (State, error) {
+	state := State{Entries: map[string]EntryState{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("schedule: invalid state file %s: %w", path, err)
+	}
+	if state.Entries == nil {
+		state.Entries = map[string]EntryState{}
+	}
+	return state, nil
+}
+
+type stateFile struct {
+	mu    sync.Mutex
+	path  string
+	state State
+}
+
+func (f *stateFile) lastFired(name string) (time.Time, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e, ok := f.state.Entries[name]
+	return e.LastFired, ok
+}
+
+// record the activation time and persist the state
+func (f *stateFile) fired(name string, scheduled time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if e, ok := f.state.Entries[name]; ok && !scheduled.After(e.LastFired) {
+		return nil
+	}
+	f.state.Entries[name] = EntryState{LastFired: scheduled}
+	return f.writeLocked()
+}
+
+// write the state atomically
+func (f *stateFile) writeLocked() error {
+	data, err := json.MarshalIndent(f.state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if err := errors.Join(err, tmp.Sync(), tmp.Close()); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
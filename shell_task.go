@@ -4,15 +4,28 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
+
+	"github.com/dunv/utask/output"
 )
 
 type shellTask struct {
-	opts options[shellTaskOptions]
-	cmd  *exec.Cmd
+	opts      options[shellTaskOptions]
+	lifecycle *lifecycle
+	cmd       *exec.Cmd
+	redact    func(string) string
+
+	// last lines of stderr (only if WithShellStderrTail is used)
+	stderrTail interface {
+		io.Writer
+		Flush() error
+		Tail() []string
+	}
 }
 
 // Create a new shell task
@@ -39,7 +52,23 @@ func NewShellTask(opts ...ShellTaskOption) (Task, error) {
 		mergedOpts.ctx = context.Background()
 	}
 
-	return &shellTask{opts: mergedOpts}, nil
+	if mergedOpts.name == "" {
+		mergedOpts.name = filepath.Base(mergedOpts.specific.shellCommand)
+	}
+
+	slogOut := attachSlog(&mergedOpts)
+	redact := redactOutput(&mergedOpts)
+	if slogOut != nil {
+		slogOut.redact = redact
+	}
+
+	description := redact(fmt.Sprintf("command '%s %s'", mergedOpts.specific.shellCommand, strings.Join(mergedOpts.specific.shellArgs, " ")))
+	lifecycle, err := newLifecycle(mergedOpts, description)
+	if err != nil {
+		return nil, err
+	}
+
+	return &shellTask{opts: mergedOpts, lifecycle: lifecycle, redact: redact}, nil
 }
 
 // Runs the task and waits for it to complete
@@ -83,6 +112,15 @@ func (t *shellTask) Start() error {
 		cmd.Stderr = t.opts.stderr
 	}
 
+	if t.opts.specific.stderrTail > 0 {
+		t.stderrTail = output.NewHeadTailLines(0, t.opts.specific.stderrTail)
+		if cmd.Stderr != nil {
+			cmd.Stderr = io.MultiWriter(cmd.Stderr, t.stderrTail)
+		} else {
+			cmd.Stderr = t.stderrTail
+		}
+	}
+
 	if t.opts.specific.shellWaitDelay > 0 {
 		cmd.WaitDelay = t.opts.specific.shellWaitDelay
 	}
@@ -91,15 +129,19 @@ func (t *shellTask) Start() error {
 	t.cmd = cmd
 
 	// initial output (helps for debugging what is actually being run here)
-	if t.opts.printStartAndEndInOutput {
-		t.printStdOut("Running command '%s %s'", t.opts.specific.shellCommand, strings.Join(t.opts.specific.shellArgs, " "))
-	}
+	t.lifecycle.start()
 
 	err := cmd.Start()
 	if err != nil {
 		t.printStdErr(err.Error())
+		t.lifecycle.end(err)
 		return err
 	}
+	t.lifecycle.running(cmd.Process.Pid)
+
+	if t.opts.specific.forwarder != nil {
+		t.opts.specific.forwarder.add(t, t.opts.specific.forwardSignals)
+	}
 
 	return nil
 }
@@ -110,28 +152,35 @@ func (t *shellTask) Wait() error {
 		return errors.New("utask: not started")
 	}
 
-	if t.opts.printStartAndEndInOutput {
-		defer func() {
-			t.printStdOut("Done executing")
-		}()
+	// deregister before the process is reaped, afterwards its process group id may be reused
+	if t.opts.specific.forwarder != nil {
+		_ = waitExited(t.cmd.Process.Pid)
+		t.opts.specific.forwarder.remove(t)
 	}
-
 	err := t.cmd.Wait()
+
+	// output of the command is complete, terminate partial lines
+	flush(t.opts.stdout)
+	flush(t.opts.stderr)
+
 	if err != nil {
 		t.printStdErr(err.Error())
-		return err
 	}
-	return nil
-}
+	t.lifecycle.end(err)
 
-func (t *shellTask) String() string {
-	return fmt.Sprintf("ShellTask{command:%s, args:%s}", t.opts.specific.shellCommand, strings.Join(t.opts.specific.shellArgs, " "))
+	if err != nil && t.stderrTail != nil {
+		_ = t.stderrTail.Flush()
+		tail := t.stderrTail.Tail()
+		for i := range tail {
+			tail[i] = t.redact(tail[i])
+		}
+		return &TaskError{Err: err, StderrTail: tail}
+	}
+	return err
 }
 
-func (t *shellTask) printStdOut(format string, args ...interface{}) {
-	if t.opts.stdout != nil {
-		fmt.Fprintf(t.opts.stdout, fmt.Sprintf("%s\n", format), args...)
-	}
+func (t *shellTask) String() string {
+	return t.redact(fmt.Sprintf("ShellTask{command:%s, args:%s}", t.opts.specific.shellCommand, strings.Join(t.opts.specific.shellArgs, " ")))
 }
 
 func (t *shellTask) printStdErr(format string, args ...interface{}) {
@@ -1,6 +1,8 @@
 package utask
 
 import (
+	"fmt"
+	"os"
 	"syscall"
 	"time"
 )
@@ -12,6 +14,9 @@ type shellTaskOptions struct {
 	shellWorkingDir string
 	shellTermSignal syscall.Signal
 	shellWaitDelay  time.Duration
+	stderrTail      int
+	forwarder       *signalForwarder
+	forwardSignals  []os.Signal
 }
 
 // Options for a shell task
@@ -21,9 +26,23 @@ type ShellTaskOption taskOption[shellTaskOptions]
 // Usually used for timeouts
 var WithShellContext = withContext[shellTaskOptions]
 
+// Name of the task, available in lifecycle templates (default: base name of the command)
+var WithShellName = withName[shellTaskOptions]
+
 // Print start and end of the execution to stdout
 var WithShellPrintStartAndEndInOutput = withPrintStartAndEndInOutput[shellTaskOptions]
 
+// Templates for the banners printed by WithShellPrintStartAndEndInOutput (default: DefaultLifecycleTemplate)
+var WithShellLifecycleTemplate = withLifecycleTemplate[shellTaskOptions]
+
+// Function called when the task is running and when it ended (independent of
+// WithShellPrintStartAndEndInOutput). LifecycleStart is not emitted if the task could not be started.
+var WithShellLifecycleHook = withLifecycleHook[shellTaskOptions]
+
+// Log every line of stdout and stderr as well as lifecycle events to logger.
+// Records have the attributes task, stream, pid (shell tasks only) and run_id.
+var WithShellSlog = withSlog[shellTaskOptions]
+
 // Write stdout on the given writer (default: os.Discard)
 var WithShellStdout = withStdout[shellTaskOptions]
 
@@ -33,6 +52,22 @@ var WithShellStderr = withStderr[shellTaskOptions]
 // Write stdout and stderr on the given writer (default: os.Discard)
 var WithShellCombinedOutput = withCombinedOutput[shellTaskOptions]
 
+// Record stdout, stderr and lifecycle banners as separate streams into a single
+// ordered log (e.g. output.NewRecorder()). Implies WithShellPrintStartAndEndInOutput.
+var WithShellCombinedLog = withCombinedLog[shellTaskOptions]
+
+// Write stdout and stderr into a group of g (e.g. output.NewGrouper()) which is printed
+// as one block when the task ended, including the banners of WithShellPrintStartAndEndInOutput
+var WithShellGroupedOutput = withGroupedOutput[shellTaskOptions]
+
+// Mask the given values in all output and in String() (replaced by output.Redacted).
+// Base64 and URL encoded forms of the values are masked as well.
+var WithShellSecrets = withSecrets[shellTaskOptions]
+
+// Mask matches of the given patterns in all output and in String() (replaced by output.Redacted).
+// If a pattern contains capture groups, only the groups are masked (e.g. `token=(\S+)`).
+var WithShellSecretPatterns = withSecretPatterns[shellTaskOptions]
+
 // Shell command to be executed.
 func WithShellCommand(command string, args ...string) ShellTaskOption {
 	return newFuncTaskOption(func(o *options[shellTaskOptions]) error {
@@ -73,3 +108,28 @@ func WithShellWaitDelay(waitDelay time.Duration) ShellTaskOption {
 		return nil
 	})
 }
+
+// Keep the last n lines of stderr (even if no stderr writer is given) and attach them
+// to the error returned by Wait (as *TaskError)
+func WithShellStderrTail(n int) ShellTaskOption {
+	return newFuncTaskOption(func(o *options[shellTaskOptions]) error {
+		o.specific.stderrTail = n
+		return nil
+	})
+}
+
+// Receive signals relayed by the forwarder (see NewSignalForwarder) while the task is running.
+// If signals are given, only those are relayed to this task (default: all signals of the forwarder),
+// they must be relayed by the forwarder.
+func WithShellSignalForwarder(forwarder *signalForwarder, signals ...os.Signal) ShellTaskOption {
+	return newFuncTaskOption(func(o *options[shellTaskOptions]) error {
+		for _, sig := range signals {
+			if !containsSignal(forwarder.signals, sig) {
+				return fmt.Errorf("utask: signal %s is not relayed by the forwarder", sig)
+			}
+		}
+		o.specific.forwarder = forwarder
+		o.specific.forwardSignals = signals
+		return nil
+	})
+}
@@ -1,16 +1,23 @@
 package utask_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
 
 	"github.com/dunv/utask"
+	"github.com/dunv/utask/output"
 	"github.com/stretchr/testify/require"
 )
 
@@ -30,6 +37,270 @@ func TestShellTaskSimple(t *testing.T) {
 	)
 }
 
+func TestShellTaskFailureBanner(t *testing.T) {
+	o := utask.NewOutput()
+	task, err := utask.NewShellTask(
+		utask.WithShellPrintStartAndEndInOutput(),
+		utask.WithShellCommand("/bin/sh", "-c", "exit 1"),
+		utask.WithShellCombinedOutput(o),
+	)
+	require.NoError(t, err)
+	require.ErrorContains(t, task.Run(), "exit status 1")
+	lines := o.Lines()
+	require.Len(t, lines, 3)
+	require.Equal(t, "Running command '/bin/sh -c exit 1'", lines[0])
+	require.Equal(t, "exit status 1", lines[1])
+	require.Regexp(t, `^Failed after .+: exit status 1$`, lines[2])
+}
+
+func TestShellTaskLifecycleTemplate(t *testing.T) {
+	o := utask.NewOutput()
+	task, err := utask.NewShellTask(
+		utask.WithShellName("greeter"),
+		utask.WithShellPrintStartAndEndInOutput(),
+		utask.WithShellLifecycleTemplate(utask.LifecycleTemplate{
+			Start:   "[{{.Name}}] started",
+			Success: "[{{.Name}}] exited with {{.ExitCode}}",
+		}),
+		utask.WithShellCommand("/bin/sh", "-c", "echo hallo"),
+		utask.WithShellCombinedOutput(o),
+	)
+	require.NoError(t, err)
+	require.NoError(t, task.Run())
+	requireOutput(t, o,
+		"[greeter] started",
+		"hallo",
+		"[greeter] exited with 0",
+	)
+}
+
+func TestShellTaskInvalidLifecycleTemplate(t *testing.T) {
+	_, err := utask.NewShellTask(
+		utask.WithShellLifecycleTemplate(utask.LifecycleTemplate{Start: "{{.Name"}),
+		utask.WithShellCommand("/bin/sh", "-c", "echo hallo"),
+	)
+	require.ErrorContains(t, err, "invalid start lifecycle template")
+}
+
+func TestShellTaskCombinedLog(t *testing.T) {
+	log := output.NewRecorder()
+	task, err := utask.NewShellTask(
+		utask.WithShellCommand("/bin/sh", "-c", "echo out && sleep .05 && echo err >&2 && sleep .05 && printf partial"),
+		utask.WithShellCombinedLog(log),
+	)
+	require.NoError(t, err)
+	require.NoError(t, task.Run())
+
+	lines := []string{}
+	for i, line := range log.Snapshot() {
+		require.Equal(t, uint64(i), line.Seq)
+		lines = append(lines, string(line.Stream)+":"+line.Text)
+	}
+	require.Equal(t, []string{
+		"lifecycle:Running command '/bin/sh -c echo out && sleep .05 && echo err >&2 && sleep .05 && printf partial'",
+		"stdout:out",
+		"stderr:err",
+		"stdout:partial",
+		"lifecycle:Done executing",
+	}, lines)
+}
+
+func TestShellTaskGroupedOutput(t *testing.T) {
+	buf := &bytes.Buffer{}
+	g := output.NewGrouper(buf)
+	slow, err := utask.NewShellTask(
+		utask.WithShellName("slow"),
+		utask.WithShellCommand("/bin/sh", "-c", "echo slow1 && sleep .1 && echo slow2"),
+		utask.WithShellGroupedOutput(g),
+	)
+	require.NoError(t, err)
+	fast, err := utask.NewShellTask(
+		utask.WithShellName("fast"),
+		utask.WithShellPrintStartAndEndInOutput(),
+		utask.WithShellCommand("/bin/sh", "-c", "sleep .05 && echo fast && exit 1"),
+		utask.WithShellGroupedOutput(g),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, slow.Start())
+	require.Error(t, fast.Run())
+	// fast ended first and is printed without waiting for slow
+	require.Regexp(t, `^==> fast\nRunning command '.+'\nfast\nexit status 1\nFailed after .+\n<== fast \(failed after .+: exit status 1\)\n$`, buf.String())
+	require.NoError(t, slow.Wait())
+	require.Regexp(t, `\n==> slow\nslow1\nslow2\n<== slow \(done in .+\)\n$`, buf.String())
+}
+
+func TestShellTaskHeadTailCapture(t *testing.T) {
+	stdout := output.NewHeadTailLines(2, 2)
+	task, err := utask.NewShellTask(
+		utask.WithShellCommand("/bin/sh", "-c", "seq 1 10000"),
+		utask.WithShellStdout(stdout),
+	)
+	require.NoError(t, err)
+	require.NoError(t, task.Run())
+	require.Equal(t, "1\n2\n... 9,996 lines omitted ...\n9999\n10000\n", stdout.String())
+}
+
+func TestShellTaskStderrTail(t *testing.T) {
+	task, err := utask.NewShellTask(
+		utask.WithShellCommand("/bin/sh", "-c", "echo 1 >&2 && echo 2 >&2 && echo 3 >&2 && echo out && exit 3"),
+		utask.WithShellStderrTail(2),
+	)
+	require.NoError(t, err)
+	err = task.Run()
+	require.Equal(t, "exit status 3\nstderr (last 2 lines):\n  2\n  3", err.Error())
+
+	taskErr := &utask.TaskError{}
+	require.ErrorAs(t, err, &taskErr)
+	require.Equal(t, []string{"2", "3"}, taskErr.StderrTail)
+
+	exitErr := &exec.ExitError{}
+	require.ErrorAs(t, err, &exitErr)
+	require.Equal(t, 3, exitErr.ExitCode())
+}
+
+func TestShellTaskStderrTailWithWriter(t *testing.T) {
+	stderr := utask.NewOutput()
+	task, err := utask.NewShellTask(
+		utask.WithShellCommand("/bin/sh", "-c", "echo failure >&2 && exit 1"),
+		utask.WithShellStderr(stderr),
+		utask.WithShellStderrTail(5),
+	)
+	require.NoError(t, err)
+	require.ErrorContains(t, task.Run(), "exit status 1\nstderr (last 1 lines):\n  failure")
+	requireOutput(t, stderr, "failure", "exit status 1")
+}
+
+func TestShellTaskSecrets(t *testing.T) {
+	o := utask.NewOutput()
+	task, err := utask.NewShellTask(
+		utask.WithShellPrintStartAndEndInOutput(),
+		utask.WithShellCommand("/bin/sh", "-c", "printf $TOKEN && printf '\\n' && printf \"$TOKEN\" | base64 && echo api_key=abc123 >&2", "--token=topsecret"),
+		utask.WithShellEnvironment([]string{"TOKEN=topsecret"}),
+		utask.WithShellCombinedOutput(o),
+		utask.WithShellSecrets("topsecret"),
+		utask.WithShellSecretPatterns(regexp.MustCompile(`api_key=(\S+)`)),
+	)
+	require.NoError(t, err)
+	require.NotContains(t, task.(fmt.Stringer).String(), "topsecret")
+	require.NoError(t, task.Run())
+	require.NotContains(t, o.String(), "topsecret")
+	require.NotContains(t, o.String(), "dG9wc2VjcmV0")
+	require.Contains(t, o.String(), "api_key=***")
+	require.Contains(t, o.String(), "--token=***")
+}
+
+func TestShellTaskSlog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	events := []utask.LifecycleEvent{}
+	task, err := utask.NewShellTask(
+		utask.WithShellName("greeter"),
+		utask.WithShellCommand("/bin/sh", "-c", "echo hello && echo failure >&2 && exit 2"),
+		utask.WithShellSlog(logger),
+		utask.WithShellLifecycleHook(func(e utask.LifecycleEvent) {
+			events = append(events, e)
+		}),
+	)
+	require.NoError(t, err)
+	require.ErrorContains(t, task.Run(), "exit status 2")
+
+	require.Len(t, events, 2)
+	require.Equal(t, utask.LifecycleStart, events[0].Phase)
+	require.Equal(t, utask.LifecycleFailure, events[1].Phase)
+	require.Equal(t, 2, events[1].ExitCode)
+	require.NotZero(t, events[0].Pid)
+	require.NotEmpty(t, events[0].RunID)
+
+	records := []map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		record := map[string]any{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		require.Equal(t, "greeter", record["task"])
+		require.Equal(t, float64(events[0].Pid), record["pid"])
+		require.Equal(t, events[0].RunID, record["run_id"])
+		records = append(records, record)
+	}
+
+	// lines of stdout and stderr are logged concurrently
+	require.Len(t, records, 5)
+	require.Equal(t, "task started", records[0]["msg"])
+	require.ElementsMatch(t, []string{"stdout:hello", "stderr:failure"}, []string{
+		records[1]["stream"].(string) + ":" + records[1]["msg"].(string),
+		records[2]["stream"].(string) + ":" + records[2]["msg"].(string),
+	})
+	require.Equal(t, "exit status 2", records[3]["msg"])
+	require.Equal(t, "stderr", records[3]["stream"])
+	require.Equal(t, "task failed", records[4]["msg"])
+	require.Equal(t, "ERROR", records[4]["level"])
+	require.Equal(t, float64(2), records[4]["exit_code"])
+	require.Equal(t, "exit status 2", records[4]["error"])
+}
+
+func TestShellTaskSlogCanceledRetry(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			switch a.Key {
+			case slog.TimeKey, "duration", "run_id", "pid", "description":
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	o := utask.NewOutput()
+	ctx, cancel := context.WithTimeout(utask.ContextWithAttempt(context.Background(), 2), 100*time.Millisecond)
+	defer cancel()
+	events := []utask.LifecycleEvent{}
+	task, err := utask.NewShellTask(
+		utask.WithShellContext(ctx),
+		utask.WithShellName("sleeper"),
+		utask.WithShellCommand("/bin/sh", "-c", "sleep 10"),
+		utask.WithShellPrintStartAndEndInOutput(),
+		utask.WithShellStdout(o),
+		utask.WithShellSlog(logger),
+		utask.WithShellLifecycleHook(func(e utask.LifecycleEvent) {
+			events = append(events, e)
+		}),
+	)
+	require.NoError(t, err)
+	require.ErrorContains(t, task.Run(), "signal: terminated")
+
+	require.Len(t, events, 2)
+	require.Equal(t, 2, events[1].Attempt)
+	require.True(t, events[1].Canceled)
+
+	// banners are printed, but only logged as lifecycle records
+	require.Len(t, o.Lines(), 2)
+	require.Equal(t, strings.Join([]string{
+		`level=WARN msg="task retried" task=sleeper attempt=2`,
+		`level=INFO msg="signal: terminated" task=sleeper stream=stderr`,
+		`level=WARN msg="task failed" task=sleeper attempt=2 exit_code=-1 error="signal: terminated"`,
+	}, "\n")+"\n", buf.String())
+}
+
+func TestShellTaskTerminal(t *testing.T) {
+	buf := &bytes.Buffer{}
+	term := output.NewTerminal(buf)
+	task, err := utask.NewShellTask(
+		utask.WithShellCommand("/bin/sh", "-c", `for i in 1 2 3; do printf "\r\033[Kprogress $i/3"; done; echo`),
+		utask.WithShellStdout(term),
+	)
+	require.NoError(t, err)
+	require.NoError(t, task.Run())
+	require.NoError(t, term.Close())
+	require.Equal(t, "progress 3/3\n", buf.String())
+}
+
 func TestShellTaskInitErrorCombined(t *testing.T) {
 	o := utask.NewOutput()
 	task, err := utask.NewShellTask(
@@ -130,6 +401,54 @@ func TestShellTaskCancel(t *testing.T) {
 	requireOutput(t, stderr, "signal: terminated")
 }
 
+func TestShellTaskSignalForwarder(t *testing.T) {
+	forwarder := utask.NewSignalForwarder(syscall.SIGUSR1, syscall.SIGUSR2)
+	defer forwarder.Stop()
+
+	// the script announces that its traps are installed by creating a file
+	dir := t.TempDir()
+	start := func(name string, signals ...os.Signal) (utask.Task, *bytes.Buffer) {
+		stdout := &bytes.Buffer{}
+		script := fmt.Sprintf(`trap "echo got USR1; exit 0" USR1; trap "echo got USR2; exit 0" USR2; touch %s; while true; do sleep 0.01; done`, filepath.Join(dir, name))
+		task, err := utask.NewShellTask(
+			utask.WithShellCommand("/bin/sh", "-c", script),
+			utask.WithShellStdout(stdout),
+			utask.WithShellSignalForwarder(forwarder, signals...),
+		)
+		require.NoError(t, err)
+		require.NoError(t, task.Start())
+		require.Eventually(t, func() bool {
+			_, err := os.Stat(filepath.Join(dir, name))
+			return err == nil
+		}, 5*time.Second, 5*time.Millisecond)
+		return task, stdout
+	}
+
+	all, allOut := start("all")
+	onlyUSR2, onlyUSR2Out := start("usr2", syscall.SIGUSR2)
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	require.NoError(t, all.Wait())
+	require.Equal(t, "got USR1\n", allOut.String())
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR2))
+	require.NoError(t, onlyUSR2.Wait())
+	require.Equal(t, "got USR2\n", onlyUSR2Out.String())
+
+	// stopping twice is fine
+	forwarder.Stop()
+}
+
+func TestShellTaskSignalForwarderUnknownSignal(t *testing.T) {
+	forwarder := utask.NewSignalForwarder(syscall.SIGUSR1)
+	defer forwarder.Stop()
+	_, err := utask.NewShellTask(
+		utask.WithShellCommand("/bin/sh", "-c", "true"),
+		utask.WithShellSignalForwarder(forwarder, syscall.SIGUSR2),
+	)
+	require.ErrorContains(t, err, "signal user defined signal 2 is not relayed by the forwarder")
+}
+
 // Added for manual tests
 func TestShellTaskAnsibleTimeout(t *testing.T) {
 	if os.Getenv("CI") == "true" {
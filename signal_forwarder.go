@@ -0,0 +1,127 @@
+package utask
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// Signals relayed by a signal forwarder if none are given
+var DefaultForwardedSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1}
+
+// Relays signals received by the current process to the process groups of running
+// shell tasks (see WithShellSignalForwarder). Shell tasks run in their own process
+// group, so signals sent to the parent (e.g. SIGTERM by a service manager) do not reach them otherwise.
+//
+// While the forwarder is active, the signals it relays no longer terminate the current
+// process (see signal.Notify), use signal.NotifyContext or similar to shut down.
+// Stop restores the previous behavior.
+type signalForwarder struct {
+	mu      sync.Mutex
+	signals []os.Signal
+	tasks   map[*shellTask][]os.Signal
+	ch      chan os.Signal
+	done    chan struct{}
+	stopped bool
+}
+
+// Start relaying the given signals (default: DefaultForwardedSignals)
+func NewSignalForwarder(signals ...os.Signal) *signalForwarder {
+	if len(signals) == 0 {
+		signals = DefaultForwardedSignals
+	}
+
+	f := &signalForwarder{
+		signals: signals,
+		tasks:   map[*shellTask][]os.Signal{},
+		ch:      make(chan os.Signal, 8),
+		done:    make(chan struct{}),
+	}
+	signal.Notify(f.ch, signals...)
+	go f.loop()
+	return f
+}
+
+// Stop relaying signals. Running tasks stay registered, but receive no further signals.
+func (f *signalForwarder) Stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stopped {
+		return
+	}
+	f.stopped = true
+	signal.Stop(f.ch)
+	close(f.done)
+}
+
+func (f *signalForwarder) loop() {
+	for {
+		select {
+		case sig := <-f.ch:
+			f.forward(sig)
+		case <-f.done:
+			return
+		}
+	}
+}
+
+// send sig to the process group of every registered task accepting it
+func (f *signalForwarder) forward(sig os.Signal) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stopped {
+		return
+	}
+	for t, filter := range f.tasks {
+		if !containsSignal(filter, sig) {
+			continue
+		}
+		if s, ok := sig.(syscall.Signal); ok {
+			_ = syscall.Kill(-t.cmd.Process.Pid, s)
+		}
+	}
+}
+
+// register a started task, filter restricts the signals it receives (nil: all)
+func (f *signalForwarder) add(t *shellTask, filter []os.Signal) {
+	if filter == nil {
+		filter = f.signals
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tasks[t] = filter
+}
+
+func (f *signalForwarder) remove(t *shellTask) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.tasks, t)
+}
+
+func containsSignal(signals []os.Signal, sig os.Signal) bool {
+	for _, s := range signals {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// block until the process exited without reaping it, its pid (and process group id)
+// cannot be reused until it is reaped by cmd.Wait
+func waitExited(pid int) error {
+	const pPID = 1     // idtype_t P_PID
+	var info [128]byte // siginfo_t
+	for {
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pPID, uintptr(pid), uintptr(unsafe.Pointer(&info[0])), syscall.WEXITED|syscall.WNOWAIT, 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	}
+}
@@ -0,0 +1,141 @@
+package utask
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/dunv/utask/output"
+)
+
+// log output and lifecycle events to the configured slog.Logger (if any)
+func attachSlog[T specificOptions](o *options[T]) *slogOutput {
+	if o.logger == nil {
+		return nil
+	}
+
+	// a writer used for both streams has to stay serialized after being tee'd
+	if sameWriter(o.stdout, o.stderr) {
+		shared := &syncWriter{w: o.stdout}
+		o.stdout, o.stderr = shared, shared
+	}
+
+	// banners are logged as lifecycle records, they must not be logged again as stdout
+	if o.lifecycleOutput == nil {
+		o.lifecycleOutput = o.stdout
+		if o.lifecycleOutput == nil {
+			o.lifecycleOutput = io.Discard
+		}
+	}
+
+	s := newSlogOutput(o.logger, o.name)
+	o.stdout = tee(o.stdout, s.writer(output.Stdout))
+	o.stderr = tee(o.stderr, s.writer(output.Stderr))
+	o.lifecycleHooks = append(o.lifecycleHooks, s.event)
+	return s
+}
+
+// logs output lines and lifecycle events of a task to a slog.Logger
+type slogOutput struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+	name   string
+	runID  string
+	pid    int
+	redact func(string) string
+}
+
+func newSlogOutput(logger *slog.Logger, name string) *slogOutput {
+	return &slogOutput{
+		logger: logger,
+		name:   name,
+		redact: func(s string) string { return s },
+	}
+}
+
+// line-buffered writer logging every line with the given stream attribute
+func (s *slogOutput) writer(stream output.Stream) io.Writer {
+	return newLineWriter(&slogLines{output: s, stream: stream})
+}
+
+// lifecycle hook
+func (s *slogOutput) event(e LifecycleEvent) {
+	s.mu.Lock()
+	s.runID, s.pid = e.RunID, e.Pid
+	s.mu.Unlock()
+
+	attrs := append(s.attrs(), slog.String("description", e.Description))
+	if e.Attempt > 0 {
+		attrs = append(attrs, slog.Int("attempt", e.Attempt))
+	}
+	switch e.Phase {
+	case LifecycleStart:
+		// a retry means an earlier attempt failed
+		if e.Attempt > 1 {
+			s.logger.LogAttrs(context.Background(), slog.LevelWarn, "task retried", attrs...)
+			return
+		}
+		s.logger.LogAttrs(context.Background(), slog.LevelInfo, "task started", attrs...)
+	case LifecycleSuccess:
+		attrs = append(attrs, slog.Int("exit_code", e.ExitCode), slog.Duration("duration", e.Duration))
+		s.logger.LogAttrs(context.Background(), slog.LevelInfo, "task succeeded", attrs...)
+	case LifecycleFailure:
+		attrs = append(attrs,
+			slog.Int("exit_code", e.ExitCode),
+			slog.Duration("duration", e.Duration),
+			slog.String("error", s.redact(e.Err.Error())),
+		)
+		// tasks ended from the outside (timeout, cancel) are not an error of the task itself
+		level := slog.LevelError
+		if e.Canceled {
+			level = slog.LevelWarn
+		}
+		s.logger.LogAttrs(context.Background(), level, "task failed", attrs...)
+	}
+}
+
+func (s *slogOutput) attrs() []slog.Attr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attrs := []slog.Attr{slog.String("task", s.name)}
+	if s.pid != 0 {
+		attrs = append(attrs, slog.Int("pid", s.pid))
+	}
+	if s.runID != "" {
+		attrs = append(attrs, slog.String("run_id", s.runID))
+	}
+	return attrs
+}
+
+// receives complete lines from a lineWriter
+type slogLines struct {
+	output *slogOutput
+	stream output.Stream
+}
+
+func (l *slogLines) Write(p []byte) (n int, err error) {
+	attrs := append(l.output.attrs(), slog.String("stream", string(l.stream)))
+	for _, line := range splitLines(p) {
+		l.output.logger.LogAttrs(context.Background(), slog.LevelInfo, line, attrs...)
+	}
+	return len(p), nil
+}
+
+// split newline-terminated lines
+func splitLines(p []byte) []string {
+	lines := []string{}
+	start := 0
+	for i, b := range p {
+		if b == '\n' {
+			line := p[start:i]
+			if len(line) > 0 && line[len(line)-1] == '\r' {
+				line = line[:len(line)-1]
+			}
+			lines = append(lines, string(line))
+			start = i + 1
+		}
+	}
+	return lines
+}
@@ -0,0 +1,253 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dunv/utask"
+)
+
+// Returned by Run if the task was restarted too often (see WithCrashLoopGuard)
+var ErrCrashLoop = errors.New("supervisor: crash loop detected")
+
+// When a task is restarted after it ended (similar to systemd's Restart=)
+type RestartPolicy int
+
+const (
+	// Restart if the task failed (default)
+	RestartOnFailure RestartPolicy = iota
+	// Restart whenever the task ended
+	RestartAlways
+	// Never restart
+	RestartNever
+)
+
+func (p RestartPolicy) String() string {
+	switch p {
+	case RestartAlways:
+		return "always"
+	case RestartNever:
+		return "never"
+	default:
+		return "on-failure"
+	}
+}
+
+// Why a run of the task ended
+type ExitReason string
+
+const (
+	// The task ended without error
+	ExitSuccess ExitReason = "success"
+	// The task ended with an error (e.g. non-zero exit code)
+	ExitFailure ExitReason = "exit-code"
+	// The process was killed by a signal
+	ExitSignal ExitReason = "signal"
+	// The task could not be created or started
+	ExitStartFailure ExitReason = "start-failure"
+	// The supervisor was stopped
+	ExitCanceled ExitReason = "canceled"
+)
+
+// Outcome of a single run of the supervised task
+type Exit struct {
+	Reason ExitReason
+	// Exit code of shell tasks, -1 if unknown
+	ExitCode int
+	Err      error
+	Started  time.Time
+	Ended    time.Time
+}
+
+// Current state of a supervisor
+type Status struct {
+	Running bool
+	// Number of times the task was restarted
+	Restarts int
+	// Outcome of the last run, nil if the task did not end yet
+	LastExit *Exit
+	// Time the next restart is scheduled for, zero if there is none
+	NextRestart time.Time
+}
+
+// Keeps a task alive by recreating it with its factory whenever it ended
+type supervisor struct {
+	factory utask.TaskFactory
+	opts    supervisorOptions
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Create a supervisor for the tasks created by factory. The context passed to
+// factory is canceled once the supervisor is stopped.
+func New(factory utask.TaskFactory, opts ...Option) *supervisor {
+	mergedOpts := supervisorOptions{
+		policy:          RestartOnFailure,
+		initialBackoff:  100 * time.Millisecond,
+		maxBackoff:      30 * time.Second,
+		crashLoopBurst:  5,
+		crashLoopWindow: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt.apply(&mergedOpts)
+	}
+	if mergedOpts.backoffResetTime == 0 {
+		mergedOpts.backoffResetTime = mergedOpts.maxBackoff
+	}
+
+	return &supervisor{
+		factory: factory,
+		opts:    mergedOpts,
+	}
+}
+
+// Run and restart the task until the restart policy says otherwise or ctx is done.
+// Returns
+//   - the error of the last run if it is not restarted
+//   - ErrCrashLoop (wrapping the error of the last run) if restarted too often
+//   - ctx.Err() if ctx is done
+func (s *supervisor) Run(ctx context.Context) error {
+	backoff := s.opts.initialBackoff
+	restarts := []time.Time{}
+	for {
+		exit := s.runOnce(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if !s.shouldRestart(exit) {
+			return exit.Err
+		}
+
+		// crash-loop guard: at most crashLoopBurst restarts within crashLoopWindow
+		now := time.Now()
+		if s.opts.crashLoopBurst > 0 {
+			recent := restarts[:0]
+			for _, t := range restarts {
+				if now.Sub(t) < s.opts.crashLoopWindow {
+					recent = append(recent, t)
+				}
+			}
+			restarts = recent
+			if len(restarts) >= s.opts.crashLoopBurst {
+				return fmt.Errorf("%w: %d restarts within %s: %w", ErrCrashLoop, len(restarts), s.opts.crashLoopWindow, exit.Err)
+			}
+		}
+		restarts = append(restarts, now)
+
+		// a run lasting long enough counts as stable and resets the backoff
+		if exit.Ended.Sub(exit.Started) >= s.opts.backoffResetTime {
+			backoff = s.opts.initialBackoff
+		}
+
+		s.mu.Lock()
+		s.status.NextRestart = now.Add(backoff)
+		s.mu.Unlock()
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.mu.Lock()
+			s.status.NextRestart = time.Time{}
+			s.mu.Unlock()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if backoff > s.opts.maxBackoff {
+			backoff = s.opts.maxBackoff
+		}
+
+		s.mu.Lock()
+		s.status.Restarts++
+		s.status.NextRestart = time.Time{}
+		s.mu.Unlock()
+		if s.opts.restartHook != nil {
+			s.opts.restartHook(s.Status())
+		}
+	}
+}
+
+// Current state of the supervisor, safe to be called concurrently to Run
+func (s *supervisor) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.status
+	if status.LastExit != nil {
+		exit := *status.LastExit
+		status.LastExit = &exit
+	}
+	return status
+}
+
+func (s *supervisor) shouldRestart(exit Exit) bool {
+	switch s.opts.policy {
+	case RestartAlways:
+		return true
+	case RestartNever:
+		return false
+	default:
+		return exit.Reason != ExitSuccess
+	}
+}
+
+func (s *supervisor) runOnce(ctx context.Context) Exit {
+	exit := Exit{Started: time.Now()}
+
+	s.mu.Lock()
+	s.status.Running = true
+	attempt := s.status.Restarts + 1
+	s.mu.Unlock()
+
+	task, err := s.factory(utask.ContextWithAttempt(ctx, attempt))
+	if err == nil {
+		if err = task.Start(); err != nil {
+			exit.Reason = ExitStartFailure
+		} else {
+			err = task.Wait()
+		}
+	} else {
+		exit.Reason = ExitStartFailure
+	}
+
+	exit.Ended = time.Now()
+	exit.Err = err
+	exit.ExitCode = -1
+	if exit.Reason == "" {
+		exit.Reason, exit.ExitCode = classify(err)
+	}
+	if ctx.Err() != nil {
+		exit.Reason = ExitCanceled
+	}
+
+	s.mu.Lock()
+	s.status.Running = false
+	s.status.LastExit = &exit
+	s.mu.Unlock()
+	if s.opts.exitHook != nil {
+		s.opts.exitHook(exit)
+	}
+	return exit
+}
+
+func classify(err error) (ExitReason, int) {
+	if err == nil {
+		return ExitSuccess, 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return ExitSignal, -1
+		}
+		return ExitFailure, exitErr.ExitCode()
+	}
+	return ExitFailure, -1
+}
@@ -0,0 +1,77 @@
+package supervisor
+
+import "time"
+
+type supervisorOptions struct {
+	policy           RestartPolicy
+	initialBackoff   time.Duration
+	maxBackoff       time.Duration
+	backoffResetTime time.Duration
+	crashLoopBurst   int
+	crashLoopWindow  time.Duration
+	exitHook         func(Exit)
+	restartHook      func(Status)
+}
+
+// Options for a supervisor
+type Option interface {
+	apply(*supervisorOptions)
+}
+
+type funcOption struct {
+	f func(*supervisorOptions)
+}
+
+// nolint:unused
+func (fo *funcOption) apply(o *supervisorOptions) {
+	fo.f(o)
+}
+
+func newFuncOption(f func(*supervisorOptions)) *funcOption {
+	return &funcOption{f: f}
+}
+
+// When the task is restarted (default: RestartOnFailure)
+func WithRestart(policy RestartPolicy) Option {
+	return newFuncOption(func(o *supervisorOptions) {
+		o.policy = policy
+	})
+}
+
+// Delay before restarting, doubled on every consecutive restart up to max (default: 100ms, 30s)
+func WithBackoff(initial time.Duration, max time.Duration) Option {
+	return newFuncOption(func(o *supervisorOptions) {
+		o.initialBackoff = initial
+		o.maxBackoff = max
+	})
+}
+
+// Runs lasting at least d reset the backoff to its initial value (default: the max backoff)
+func WithBackoffReset(d time.Duration) Option {
+	return newFuncOption(func(o *supervisorOptions) {
+		o.backoffResetTime = d
+	})
+}
+
+// Give up with ErrCrashLoop if the task would be restarted more than maxRestarts times
+// within window (default: 5 in 10s, maxRestarts 0 disables the guard)
+func WithCrashLoopGuard(maxRestarts int, window time.Duration) Option {
+	return newFuncOption(func(o *supervisorOptions) {
+		o.crashLoopBurst = maxRestarts
+		o.crashLoopWindow = window
+	})
+}
+
+// Function called every time a run of the task ended
+func WithExitHook(hook func(Exit)) Option {
+	return newFuncOption(func(o *supervisorOptions) {
+		o.exitHook = hook
+	})
+}
+
+// Function called every time the task is restarted
+func WithRestartHook(hook func(Status)) Option {
+	return newFuncOption(func(o *supervisorOptions) {
+		o.restartHook = hook
+	})
+}
@@ -0,0 +1,132 @@
+package supervisor_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dunv/utask"
+	"github.com/dunv/utask/supervisor"
+	"github.com/stretchr/testify/require"
+)
+
+func shellFactory(script string) utask.TaskFactory {
+	return func(ctx context.Context) (utask.Task, error) {
+		return utask.NewShellTask(
+			utask.WithShellContext(ctx),
+			utask.WithShellCommand("sh", "-c", script),
+		)
+	}
+}
+
+func TestSupervisorRestartOnFailure(t *testing.T) {
+	// fails twice, then succeeds
+	counter := filepath.Join(t.TempDir(), "counter")
+	script := fmt.Sprintf(`echo x >> %s; [ $(wc -l < %s) -ge 3 ] || exit 3`, counter, counter)
+
+	exits := []supervisor.Exit{}
+	s := supervisor.New(shellFactory(script),
+		supervisor.WithBackoff(time.Millisecond, 10*time.Millisecond),
+		supervisor.WithExitHook(func(e supervisor.Exit) { exits = append(exits, e) }),
+	)
+	require.NoError(t, s.Run(context.Background()))
+
+	require.Len(t, exits, 3)
+	require.Equal(t, supervisor.ExitFailure, exits[0].Reason)
+	require.Equal(t, 3, exits[0].ExitCode)
+	require.Equal(t, supervisor.ExitSuccess, exits[2].Reason)
+
+	status := s.Status()
+	require.Equal(t, 2, status.Restarts)
+	require.False(t, status.Running)
+	require.Equal(t, supervisor.ExitSuccess, status.LastExit.Reason)
+}
+
+func TestSupervisorRestartNever(t *testing.T) {
+	s := supervisor.New(shellFactory("exit 2"), supervisor.WithRestart(supervisor.RestartNever))
+	err := s.Run(context.Background())
+	require.EqualError(t, err, "exit status 2")
+	require.Equal(t, 0, s.Status().Restarts)
+	require.Equal(t, 2, s.Status().LastExit.ExitCode)
+}
+
+func TestSupervisorCrashLoop(t *testing.T) {
+	s := supervisor.New(shellFactory("exit 1"),
+		supervisor.WithRestart(supervisor.RestartAlways),
+		supervisor.WithBackoff(time.Millisecond, time.Millisecond),
+		supervisor.WithCrashLoopGuard(3, time.Minute),
+	)
+	err := s.Run(context.Background())
+	require.ErrorIs(t, err, supervisor.ErrCrashLoop)
+	require.ErrorContains(t, err, "exit status 1")
+	require.Equal(t, 3, s.Status().Restarts)
+}
+
+func TestSupervisorRestartAlways(t *testing.T) {
+	var runs atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := supervisor.New(func(ctx context.Context) (utask.Task, error) {
+		return utask.NewFunctionTask(utask.WithFunction(func(ctx context.Context, stdout, stderr io.Writer) error {
+			if runs.Add(1) == 4 {
+				cancel()
+			}
+			return nil
+		}))
+	},
+		supervisor.WithRestart(supervisor.RestartAlways),
+		supervisor.WithBackoff(time.Millisecond, time.Millisecond),
+		supervisor.WithCrashLoopGuard(0, 0),
+	)
+	require.ErrorIs(t, s.Run(ctx), context.Canceled)
+	require.Equal(t, int32(4), runs.Load())
+}
+
+func TestSupervisorBackoff(t *testing.T) {
+	var delays []time.Duration
+	s := supervisor.New(shellFactory("exit 1"),
+		supervisor.WithBackoff(10*time.Millisecond, 40*time.Millisecond),
+		supervisor.WithCrashLoopGuard(5, time.Minute),
+		supervisor.WithRestartHook(func(status supervisor.Status) {
+			delays = append(delays, time.Since(status.LastExit.Ended))
+		}),
+	)
+	require.ErrorIs(t, s.Run(context.Background()), supervisor.ErrCrashLoop)
+	require.Len(t, delays, 5)
+	for i, min := range []time.Duration{10, 20, 40, 40, 40} {
+		require.GreaterOrEqual(t, delays[i], min*time.Millisecond)
+	}
+}
+
+func TestSupervisorStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := supervisor.New(shellFactory("sleep 10"))
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool { return s.Status().Running }, time.Second, time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	require.Equal(t, supervisor.ExitCanceled, s.Status().LastExit.Reason)
+}
+
+func TestSupervisorStartFailure(t *testing.T) {
+	s := supervisor.New(func(ctx context.Context) (utask.Task, error) {
+		return nil, errors.New("no task")
+	}, supervisor.WithRestart(supervisor.RestartNever))
+	require.EqualError(t, s.Run(context.Background()), "no task")
+	require.Equal(t, supervisor.ExitStartFailure, s.Status().LastExit.Reason)
+}
+
+func TestSupervisorSignal(t *testing.T) {
+	s := supervisor.New(shellFactory("kill -9 $$"), supervisor.WithRestart(supervisor.RestartNever))
+	require.Error(t, s.Run(context.Background()))
+	require.Equal(t, supervisor.ExitSignal, s.Status().LastExit.Reason)
+}
@@ -1,5 +1,7 @@
 package utask
 
+import "context"
+
 type Task interface {
 	// Runs the task and waits for it to complete
 	Run() error
@@ -9,3 +11,23 @@ type Task interface {
 	// Wait for the task to be completed. Can only be called once.
 	Wait() error
 }
+
+// Creates a fresh task for every run (e.g. of a schedule). The supplied context
+// is canceled when the run should be aborted and should be passed on to the
+// task (WithShellContext, WithFunctionContext).
+type TaskFactory func(ctx context.Context) (Task, error)
+
+type attemptKey struct{}
+
+// Context for the n-th attempt (starting at 1) of a task that is retried or restarted.
+// Passed to a TaskFactory by callers like graph and supervisor, so lifecycle events
+// and slog records of the task carry the attempt.
+func ContextWithAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// Attempt stored in ctx by ContextWithAttempt (0 if none)
+func AttemptFromContext(ctx context.Context) int {
+	attempt, _ := ctx.Value(attemptKey{}).(int)
+	return attempt
+}
@@ -3,16 +3,64 @@ package utask
 import (
 	"context"
 	"io"
+	"log/slog"
+	"regexp"
+	"text/template"
+
+	"github.com/dunv/utask/output"
 )
 
 type options[T specificOptions] struct {
 	ctx                      context.Context
+	name                     string
 	printStartAndEndInOutput bool
+	lifecycleTemplate        *template.Template
+	lifecycleOutput          io.Writer
+	lifecycleHooks           []func(LifecycleEvent)
+	logger                   *slog.Logger
 	stdout                   io.Writer
 	stderr                   io.Writer
+	groupedOutput            *groupedOutput
+	secrets                  []string
+	secretPatterns           []*regexp.Regexp
 	specific                 T
 }
 
+// Destination for a combined log of stdout, stderr and lifecycle banners,
+// e.g. output.NewRecorder()
+type CombinedLog interface {
+	Writer(stream output.Stream) io.Writer
+}
+
+// wrap all output writers so secrets are masked, returns the function used for masking
+func redactOutput[T specificOptions](o *options[T]) func(string) string {
+	if len(o.secrets) == 0 && len(o.secretPatterns) == 0 {
+		return func(s string) string { return s }
+	}
+
+	r := output.NewRedactor(o.secrets, o.secretPatterns)
+
+	// writers used for multiple streams (e.g. WithShellCombinedOutput) are wrapped
+	// only once, so writes to them stay serialized
+	wrapped := map[io.Writer]io.Writer{}
+	wrap := func(w io.Writer) io.Writer {
+		if w == nil {
+			return nil
+		}
+		if !isComparable(w) {
+			return r.Writer(w)
+		}
+		if _, ok := wrapped[w]; !ok {
+			wrapped[w] = r.Writer(w)
+		}
+		return wrapped[w]
+	}
+	o.stdout = wrap(o.stdout)
+	o.stderr = wrap(o.stderr)
+	o.lifecycleOutput = wrap(o.lifecycleOutput)
+	return r.Redact
+}
+
 type specificOptions interface {
 	functionTaskOptions | shellTaskOptions
 }
@@ -40,6 +88,13 @@ func withContext[T specificOptions](ctx context.Context) taskOption[T] {
 	})
 }
 
+func withName[T specificOptions](name string) taskOption[T] {
+	return newFuncTaskOption(func(o *options[T]) error {
+		o.name = name
+		return nil
+	})
+}
+
 func withPrintStartAndEndInOutput[T specificOptions]() taskOption[T] {
 	return newFuncTaskOption(func(o *options[T]) error {
 		o.printStartAndEndInOutput = true
@@ -47,6 +102,17 @@ func withPrintStartAndEndInOutput[T specificOptions]() taskOption[T] {
 	})
 }
 
+func withLifecycleTemplate[T specificOptions](t LifecycleTemplate) taskOption[T] {
+	return newFuncTaskOption(func(o *options[T]) error {
+		tmpl, err := parseLifecycleTemplate(t)
+		if err != nil {
+			return err
+		}
+		o.lifecycleTemplate = tmpl
+		return nil
+	})
+}
+
 func withStdout[T specificOptions](w io.Writer) taskOption[T] {
 	return newFuncTaskOption(func(o *options[T]) error {
 		o.stdout = w
@@ -68,3 +134,50 @@ func withCombinedOutput[T specificOptions](w io.Writer) taskOption[T] {
 		return nil
 	})
 }
+
+func withCombinedLog[T specificOptions](log CombinedLog) taskOption[T] {
+	return newFuncTaskOption(func(o *options[T]) error {
+		o.stdout = log.Writer(output.Stdout)
+		o.stderr = log.Writer(output.Stderr)
+		o.lifecycleOutput = log.Writer(output.Lifecycle)
+		o.printStartAndEndInOutput = true
+		return nil
+	})
+}
+
+func withGroupedOutput[T specificOptions](g Grouper) taskOption[T] {
+	return newFuncTaskOption(func(o *options[T]) error {
+		o.groupedOutput = &groupedOutput{grouper: g}
+		o.stdout = o.groupedOutput
+		o.stderr = o.groupedOutput
+		return nil
+	})
+}
+
+func withSecrets[T specificOptions](secrets ...string) taskOption[T] {
+	return newFuncTaskOption(func(o *options[T]) error {
+		o.secrets = append(o.secrets, secrets...)
+		return nil
+	})
+}
+
+func withSecretPatterns[T specificOptions](patterns ...*regexp.Regexp) taskOption[T] {
+	return newFuncTaskOption(func(o *options[T]) error {
+		o.secretPatterns = append(o.secretPatterns, patterns...)
+		return nil
+	})
+}
+
+func withLifecycleHook[T specificOptions](hook func(LifecycleEvent)) taskOption[T] {
+	return newFuncTaskOption(func(o *options[T]) error {
+		o.lifecycleHooks = append(o.lifecycleHooks, hook)
+		return nil
+	})
+}
+
+func withSlog[T specificOptions](logger *slog.Logger) taskOption[T] {
+	return newFuncTaskOption(func(o *options[T]) error {
+		o.logger = logger
+		return nil
+	})
+}
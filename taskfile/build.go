@@ -0,0 +1,273 @@
+package taskfile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dunv/utask"
+	"github.com/dunv/utask/graph"
+)
+
+var signals = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+// highest signal number (SIGRTMAX on Linux)
+const maxSignal = 64
+
+// accepts names with and without SIG prefix as well as numbers
+func parseSignal(s string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 1 || n > maxSignal {
+			return 0, fmt.Errorf("invalid signal number %d, expected 1-%d", n, maxSignal)
+		}
+		return syscall.Signal(n), nil
+	}
+	name := strings.ToUpper(s)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if sig, ok := signals[name]; ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("unknown signal %q", s)
+}
+
+type target struct {
+	discard bool
+	file    string
+}
+
+func parseTarget(s string) (target, error) {
+	switch {
+	case s == "" || s == "inherit":
+		return target{}, nil
+	case s == "discard":
+		return target{discard: true}, nil
+	case strings.HasPrefix(s, "file:") && len(s) > len("file:"):
+		return target{file: strings.TrimPrefix(s, "file:")}, nil
+	}
+	return target{}, fmt.Errorf("invalid output %q, expected inherit, discard or file:<path>", s)
+}
+
+// Graph with one node per task, honoring needs, retries and timeout.
+// The definition of a task is its node's spec (see graph.WithSpec).
+func (f *File) Graph(opts ...graph.Option) (graph.Graph, error) {
+	return f.GraphWithTaskOptions(nil, opts...)
+}
+
+// Like Graph, taskOpts are added to the options derived from every definition
+// (e.g. for output routing or signal forwarding)
+func (f *File) GraphWithTaskOptions(taskOpts []utask.ShellTaskOption, opts ...graph.Option) (graph.Graph, error) {
+	g := graph.New(opts...)
+	for _, t := range f.Tasks {
+		// the definition identifies the node when resuming a run
+		spec, err := json.Marshal(t)
+		if err != nil {
+			return nil, err
+		}
+		err = g.Add(t.Name, t.Factory(taskOpts...),
+			graph.WithSpec(spec),
+			graph.WithNeeds(t.Needs...),
+			graph.WithRetries(t.Retries),
+			graph.WithTimeout(time.Duration(t.Timeout)),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Task definition with the given name, nil if there is none
+func (f *File) Task(name string) *Task {
+	for i := range f.Tasks {
+		if f.Tasks[i].Name == name {
+			return &f.Tasks[i]
+		}
+	}
+	return nil
+}
+
+// Factory creating a shell task from the definition for every run, opts are passed
+// on to ShellTask (timeout and retries are applied by the graph, see File.Graph)
+func (t Task) Factory(opts ...utask.ShellTaskOption) utask.TaskFactory {
+	return func(ctx context.Context) (utask.Task, error) {
+		return t.ShellTask(ctx, opts...)
+	}
+}
+
+// Create a shell task from the definition, ctx is passed on to the task
+func (t Task) ShellTask(ctx context.Context, opts ...utask.ShellTaskOption) (utask.Task, error) {
+	env := os.Environ()
+	keys := make([]string, 0, len(t.Env))
+	for key := range t.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		env = append(env, key+"="+t.Env[key])
+	}
+
+	shellOpts := []utask.ShellTaskOption{
+		utask.WithShellContext(ctx),
+		utask.WithShellName(t.Name),
+		utask.WithShellCommand(t.Command, t.Args...),
+		utask.WithShellEnvironment(env),
+		utask.WithShellWorkingDir(t.WorkingDir),
+	}
+	if t.TermSignal != "" {
+		sig, err := parseSignal(t.TermSignal)
+		if err != nil {
+			return nil, err
+		}
+		shellOpts = append(shellOpts, utask.WithShellTermSignal(sig))
+	}
+	if t.WaitDelay != nil {
+		shellOpts = append(shellOpts, utask.WithShellWaitDelay(time.Duration(*t.WaitDelay)))
+	}
+
+	out := Output{}
+	if t.Output != nil {
+		out = *t.Output
+	}
+	closers := []io.Closer{}
+	stdout, err := t.openTarget(out.Stdout, os.Stdout, &closers)
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := t.openTarget(out.Stderr, os.Stderr, &closers)
+	if err != nil {
+		closeAll(closers)
+		return nil, err
+	}
+	if stdout != nil {
+		shellOpts = append(shellOpts, utask.WithShellStdout(stdout))
+	}
+	if stderr != nil {
+		shellOpts = append(shellOpts, utask.WithShellStderr(stderr))
+	}
+
+	task, err := utask.NewShellTask(append(shellOpts, opts...)...)
+	if err != nil {
+		closeAll(closers)
+		return nil, err
+	}
+	if len(closers) == 0 {
+		return task, nil
+	}
+	return &closingTask{Task: task, closers: closers}, nil
+}
+
+// writer for an output target, nil discards
+func (t Task) openTarget(s string, inherit io.Writer, closers *[]io.Closer) (io.Writer, error) {
+	target, err := parseTarget(s)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case target.discard:
+		return nil, nil
+	case target.file != "":
+		path := target.file
+		if !filepath.IsAbs(path) && t.WorkingDir != "" {
+			path = filepath.Join(t.WorkingDir, path)
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		*closers = append(*closers, f)
+		return f, nil
+	}
+	return inherit, nil
+}
+
+// closes output files once the task ended
+type closingTask struct {
+	utask.Task
+	closers []io.Closer
+}
+
+func (t *closingTask) Run() error {
+	if err := t.Start(); err != nil {
+		return err
+	}
+	return t.Wait()
+}
+
+func (t *closingTask) Start() error {
+	err := t.Task.Start()
+	if err != nil {
+		closeAll(t.closers)
+	}
+	return err
+}
+
+func (t *closingTask) Wait() error {
+	err := t.Task.Wait()
+	return errors.Join(err, closeAll(t.closers))
+}
+
+func closeAll(closers []io.Closer) error {
+	errs := []error{}
+	for _, c := range closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Subset of the file containing targets and all tasks they need (directly or indirectly),
+// in the original order
+func (f *File) Select(targets ...string) (*File, error) {
+	selected := map[string]bool{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		if selected[name] {
+			return nil
+		}
+		t := f.Task(name)
+		if t == nil {
+			return fmt.Errorf("taskfile: unknown task %q", name)
+		}
+		selected[name] = true
+		for _, need := range t.Needs {
+			if err := visit(need); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, target := range targets {
+		if err := visit(target); err != nil {
+			return nil, err
+		}
+	}
+
+	subset := &File{}
+	for _, t := range f.Tasks {
+		if selected[t.Name] {
+			subset.Tasks = append(subset.Tasks, t)
+		}
+	}
+	return subset, nil
+}
@@ -0,0 +1,282 @@
+package taskfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Serialization format of a task file
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+// Format derived from the file extension (.json is JSON, everything else YAML)
+func FormatOf(path string) Format {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
+// A set of task definitions
+//
+//	tasks:
+//	  - name: build
+//	    command: go
+//	    args: [build, ./...]
+//	    env: {CGO_ENABLED: "0"}
+//	    workingDir: ./cmd
+//	    termSignal: SIGINT   # name or number (default: SIGTERM)
+//	    waitDelay: 5s        # time between termSignal and SIGKILL (default: 1s, 0s waits forever)
+//	    timeout: 10m         # per attempt
+//	    retries: 2
+//	    needs: [generate]
+//	    output:
+//	      stdout: inherit    # inherit (default), discard or file:<path>
+//	      stderr: file:build.log
+//
+// The same structure is used for JSON, durations are strings in Go syntax.
+type File struct {
+	Tasks []Task `yaml:"tasks" json:"tasks"`
+}
+
+// Definition of a single shell task
+type Task struct {
+	Name       string            `yaml:"name" json:"name"`
+	Command    string            `yaml:"command" json:"command"`
+	Args       []string          `yaml:"args,omitempty" json:"args,omitempty"`
+	Env        map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+	WorkingDir string            `yaml:"workingDir,omitempty" json:"workingDir,omitempty"`
+	TermSignal string            `yaml:"termSignal,omitempty" json:"termSignal,omitempty"`
+	// Nil if not set, so an explicit 0s can be told apart from the default
+	WaitDelay *Duration `yaml:"waitDelay,omitempty" json:"waitDelay,omitempty"`
+	Timeout   Duration  `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Retries   int       `yaml:"retries,omitempty" json:"retries,omitempty"`
+	Needs     []string  `yaml:"needs,omitempty" json:"needs,omitempty"`
+	Output    *Output   `yaml:"output,omitempty" json:"output,omitempty"`
+
+	// line in the source file, used for validation errors
+	line int
+}
+
+// Where the output of a task is written to
+//   - "inherit": stdout/stderr of the current process (default)
+//   - "discard": nowhere
+//   - "file:<path>": appended to the file, relative paths are resolved against the working directory of the task
+type Output struct {
+	Stdout string `yaml:"stdout,omitempty" json:"stdout,omitempty"`
+	Stderr string `yaml:"stderr,omitempty" json:"stderr,omitempty"`
+}
+
+// Error in a task file, pointing to the offending line
+type Error struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// Read and validate the task file at path, the format is derived from the extension
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, path)
+}
+
+// Parse and validate a task file. YAML and JSON are both accepted,
+// name is used in error messages.
+func Parse(data []byte, name string) (*File, error) {
+	root := yaml.Node{}
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, yamlError(name, err)
+	}
+	if len(root.Content) == 0 {
+		return nil, &Error{File: name, Msg: "empty task file"}
+	}
+
+	f := &File{}
+	if err := checkKeys(name, root.Content[0], fileKeys); err != nil {
+		return nil, err
+	}
+	if err := root.Content[0].Decode(f); err != nil {
+		return nil, yamlError(name, err)
+	}
+	if err := f.validate(name); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Serialize the task file in the given format
+func (f *File) Marshal(format Format) ([]byte, error) {
+	if format == FormatJSON {
+		data, err := json.MarshalIndent(f, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	}
+
+	buf := bytes.Buffer{}
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(f); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (t *Task) UnmarshalYAML(n *yaml.Node) error {
+	if err := checkKeys("", n, taskKeys); err != nil {
+		return err
+	}
+	if out := mappingValue(n, "output"); out != nil {
+		if err := checkKeys("", out, outputKeys); err != nil {
+			return err
+		}
+	}
+
+	type plain Task
+	if err := n.Decode((*plain)(t)); err != nil {
+		return err
+	}
+	t.line = n.Line
+	return nil
+}
+
+var (
+	fileKeys   = map[string]bool{"tasks": true}
+	taskKeys   = map[string]bool{"name": true, "command": true, "args": true, "env": true, "workingDir": true, "termSignal": true, "waitDelay": true, "timeout": true, "retries": true, "needs": true, "output": true}
+	outputKeys = map[string]bool{"stdout": true, "stderr": true}
+)
+
+// reject unknown keys of a mapping node
+func checkKeys(file string, n *yaml.Node, known map[string]bool) error {
+	if n.Kind != yaml.MappingNode {
+		return &Error{File: file, Line: n.Line, Msg: "expected a mapping"}
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if key := n.Content[i]; !known[key.Value] {
+			return &Error{File: file, Line: key.Line, Msg: fmt.Sprintf("unknown field %q", key.Value)}
+		}
+	}
+	return nil
+}
+
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// add the file name to errors returned by yaml
+func yamlError(file string, err error) error {
+	var e *Error
+	if errors.As(err, &e) {
+		e.File = file
+		return e
+	}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Errorf("%s: %s", file, strings.Join(typeErr.Errors, "; "))
+	}
+	return fmt.Errorf("%s: %w", file, err)
+}
+
+func (f *File) validate(file string) error {
+	errs := []error{}
+	fail := func(t Task, format string, args ...any) {
+		errs = append(errs, &Error{File: file, Line: t.line, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	names := map[string]bool{}
+	for _, t := range f.Tasks {
+		switch {
+		case t.Name == "":
+			fail(t, "task without name")
+		case names[t.Name]:
+			fail(t, "duplicate task %q", t.Name)
+		}
+		names[t.Name] = true
+
+		for _, msg := range t.problems() {
+			fail(t, "task %q: %s", t.Name, msg)
+		}
+	}
+
+	for _, t := range f.Tasks {
+		for _, need := range t.Needs {
+			if !names[need] {
+				fail(t, "task %q needs unknown task %q", t.Name, need)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	// cycles are detected by the graph
+	if _, err := f.Graph(); err != nil {
+		return &Error{File: file, Msg: err.Error()}
+	}
+	return nil
+}
+
+// Check the definition of a single task (dependencies are checked when loading a file)
+func (t Task) Validate() error {
+	errs := []error{}
+	for _, msg := range t.problems() {
+		errs = append(errs, fmt.Errorf("taskfile: task %q: %s", t.Name, msg))
+	}
+	return errors.Join(errs...)
+}
+
+func (t Task) problems() []string {
+	problems := []string{}
+	if t.Command == "" {
+		problems = append(problems, "no command given")
+	}
+	if t.TermSignal != "" {
+		if _, err := parseSignal(t.TermSignal); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if t.Retries < 0 {
+		problems = append(problems, "retries must not be negative")
+	}
+	if (t.WaitDelay != nil && *t.WaitDelay < 0) || t.Timeout < 0 {
+		problems = append(problems, "durations must not be negative")
+	}
+	if t.Output != nil {
+		for _, target := range []string{t.Output.Stdout, t.Output.Stderr} {
+			if _, err := parseTarget(target); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+	return problems
+}
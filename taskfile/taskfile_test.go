@@ -0,0 +1,147 @@
+package taskfile_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dunv/utask"
+	"github.com/dunv/utask/taskfile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	f, err := taskfile.Load("testdata/tasks.yaml")
+	require.NoError(t, err)
+	require.Len(t, f.Tasks, 2)
+
+	build := f.Task("build")
+	require.Equal(t, []string{"generate"}, build.Needs)
+	require.Equal(t, taskfile.Duration(5*time.Second), *build.WaitDelay)
+	require.Equal(t, map[string]string{"MODE": "release"}, build.Env)
+	require.Equal(t, "file:build.log", build.Output.Stdout)
+}
+
+func TestRoundTrip(t *testing.T) {
+	data, err := os.ReadFile("testdata/tasks.yaml")
+	require.NoError(t, err)
+	f, err := taskfile.Parse(data, "tasks.yaml")
+	require.NoError(t, err)
+
+	out, err := f.Marshal(taskfile.FormatYAML)
+	require.NoError(t, err)
+	require.Equal(t, string(data), string(out))
+
+	asJSON, err := f.Marshal(taskfile.FormatJSON)
+	require.NoError(t, err)
+	fromJSON, err := taskfile.Parse(asJSON, "tasks.json")
+	require.NoError(t, err)
+	again, err := fromJSON.Marshal(taskfile.FormatJSON)
+	require.NoError(t, err)
+	require.Equal(t, string(asJSON), string(again))
+
+	out, err = fromJSON.Marshal(taskfile.FormatYAML)
+	require.NoError(t, err)
+	require.Equal(t, string(data), string(out))
+}
+
+func TestValidation(t *testing.T) {
+	for input, expected := range map[string]string{
+		"tasks:\n  - name: a\n    comand: ls\n":                                                    `tasks.yaml:3: unknown field "comand"`,
+		"tasks:\n  - name: a\n    command: ls\n    output:\n      stdot: discard\n":                `tasks.yaml:5: unknown field "stdot"`,
+		"tasks:\n  - name: a\n    command: ls\n    timeout: soon\n":                                `tasks.yaml:4: invalid duration "soon"`,
+		"tasks:\n  - name: a\n    command: ls\n    retries: many\n":                                "tasks.yaml: line 4: cannot unmarshal !!str `many` into int",
+		"tasks:\n  - name: a\n  - name: a\n    command: ls\n":                                      "tasks.yaml:2: task \"a\": no command given\ntasks.yaml:3: duplicate task \"a\"",
+		"tasks:\n  - name: a\n    command: ls\n    needs: [b]\n":                                   `tasks.yaml:2: task "a" needs unknown task "b"`,
+		"tasks:\n  - name: a\n    command: ls\n    termSignal: SIGFOO\n":                           `tasks.yaml:2: task "a": unknown signal "SIGFOO"`,
+		"tasks:\n  - name: a\n    command: ls\n    termSignal: 999\n":                              `tasks.yaml:2: task "a": invalid signal number 999, expected 1-64`,
+		"tasks:\n  - name: a\n    command: ls\n    output: {stdout: stdout}\n":                     `tasks.yaml:2: task "a": invalid output "stdout"`,
+		"tasks:\n  - {name: a, command: ls, needs: [b]}\n  - {name: b, command: ls, needs: [a]}\n": "tasks.yaml: graph: dependency cycle a -> b -> a",
+		"tasks: [\n": "tasks.yaml: yaml: line 1",
+		"":           "tasks.yaml: empty task file",
+	} {
+		_, err := taskfile.Parse([]byte(input), "tasks.yaml")
+		require.ErrorContains(t, err, expected, input)
+	}
+}
+
+func TestExplicitZeroWaitDelay(t *testing.T) {
+	f, err := taskfile.Parse([]byte("tasks:\n  - {name: a, command: ls, waitDelay: 0s}\n  - {name: b, command: ls}\n"), "tasks.yaml")
+	require.NoError(t, err)
+	require.NotNil(t, f.Task("a").WaitDelay)
+	require.Zero(t, *f.Task("a").WaitDelay)
+	require.Nil(t, f.Task("b").WaitDelay)
+
+	out, err := f.Marshal(taskfile.FormatYAML)
+	require.NoError(t, err)
+	require.Contains(t, string(out), "waitDelay: 0s")
+}
+
+func TestValidationJSON(t *testing.T) {
+	_, err := taskfile.Parse([]byte("{\n  \"tasks\": [\n    {\"name\": \"a\"}\n  ]\n}\n"), "tasks.json")
+	require.EqualError(t, err, `tasks.json:3: task "a": no command given`)
+}
+
+func TestGraph(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile("testdata/tasks.yaml")
+	require.NoError(t, err)
+	f, err := taskfile.Parse(data, "tasks.yaml")
+	require.NoError(t, err)
+	for i := range f.Tasks {
+		f.Tasks[i].WorkingDir = dir
+	}
+
+	g, err := f.Graph()
+	require.NoError(t, err)
+	require.NoError(t, g.Run(context.Background()))
+
+	log, err := os.ReadFile(filepath.Join(dir, "build.log"))
+	require.NoError(t, err)
+	require.Equal(t, "generated\nrelease\n", string(log))
+}
+
+func TestGraphWithTaskOptions(t *testing.T) {
+	f, err := taskfile.Parse([]byte("tasks:\n  - {name: a, command: echo, args: [hello]}\n"), "tasks.yaml")
+	require.NoError(t, err)
+	stdout := &bytes.Buffer{}
+	g, err := f.GraphWithTaskOptions([]utask.ShellTaskOption{utask.WithShellStdout(stdout)})
+	require.NoError(t, err)
+	require.NoError(t, g.Run(context.Background()))
+	require.Equal(t, "hello\n", stdout.String())
+}
+
+func TestShellTaskTimeout(t *testing.T) {
+	f, err := taskfile.Parse([]byte("tasks:\n  - {name: slow, command: sleep, args: ['10'], timeout: 50ms, waitDelay: 10ms}\n"), "tasks.yaml")
+	require.NoError(t, err)
+	g, err := f.Graph()
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.ErrorContains(t, g.Run(context.Background()), "slow: signal: terminated")
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestSelect(t *testing.T) {
+	f, err := taskfile.Parse([]byte(`tasks:
+  - {name: a, command: ls}
+  - {name: b, command: ls, needs: [a]}
+  - {name: c, command: ls}
+  - {name: d, command: ls, needs: [b]}
+`), "tasks.yaml")
+	require.NoError(t, err)
+
+	subset, err := f.Select("d")
+	require.NoError(t, err)
+	names := []string{}
+	for _, task := range subset.Tasks {
+		names = append(names, task.Name)
+	}
+	require.Equal(t, []string{"a", "b", "d"}, names)
+
+	_, err = f.Select("x")
+	require.EqualError(t, err, `taskfile: unknown task "x"`)
+}